@@ -0,0 +1,124 @@
+package sx
+
+import (
+	"context"
+	"database/sql"
+)
+
+// A Queryer is anything that can run queries.  Both *sql.DB and *sql.Conn are Queryers, as is *sql.Tx.
+type Queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// Conn extends a Queryer, typically an *sql.DB or an *sql.Conn, with the same Must*** methods as Tx.  Conn objects
+// are used inside of functions run by Run, to execute queries without a transaction.  Panics are caught by Run and
+// returned as errors.
+type Conn struct {
+	Queryer
+	ctx context.Context // the context passed to RunContext
+}
+
+// MustExec executes a query without returning any rows.  The args are for any placeholder parameters in the query.
+// In case of error, Run returns the error code.  The context passed to RunContext is used.
+func (conn *Conn) MustExec(query string, args ...interface{}) sql.Result {
+	return conn.MustExecContext(conn.Context(), query, args...)
+}
+
+// MustExecContext executes a query without returning any rows.  The args are for any placeholder parameters in the
+// query.  In case of error, Run returns the error code.
+func (conn *Conn) MustExecContext(ctx context.Context, query string, args ...interface{}) sql.Result {
+	return mustExec(ctx, nil, query, args, func(ctx context.Context) (sql.Result, error) {
+		return conn.ExecContext(ctx, query, args...)
+	})
+}
+
+// MustQuery executes a query that returns rows.  The args are for any placeholder parameters in the query.
+// In case of error, Run returns the error code.  The context passed to RunContext is used.
+func (conn *Conn) MustQuery(query string, args ...interface{}) *Rows {
+	return conn.MustQueryContext(conn.Context(), query, args...)
+}
+
+// MustQueryContext executes a query that returns rows.  The args are for any placeholder parameters in the query.
+// In case of error, Run returns the error code.
+func (conn *Conn) MustQueryContext(ctx context.Context, query string, args ...interface{}) *Rows {
+	return mustQuery(ctx, nil, query, args, func(ctx context.Context) (*sql.Rows, error) {
+		return conn.QueryContext(ctx, query, args...)
+	})
+}
+
+// MustQueryRow executes a query that is expected to return at most one row.  MustQueryRow always returns a non-nil
+// value.  Errors are deferred until one of the Row's scan methods is called.  The context passed to RunContext is
+// used.
+func (conn *Conn) MustQueryRow(query string, args ...interface{}) *Row {
+	return conn.MustQueryRowContext(conn.Context(), query, args...)
+}
+
+// MustQueryRowContext executes a query that is expected to return at most one row.  MustQueryRowContext always
+// returns a non-nil value.  Errors are deferred until one of the Row's scan methods is called.
+func (conn *Conn) MustQueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	return mustQueryRow(ctx, nil, query, args, func(ctx context.Context) *sql.Row {
+		return conn.QueryRowContext(ctx, query, args...)
+	})
+}
+
+// MustPrepare creates a prepared statement for later queries or executions.  Multiple queries or executions may be
+// run concurrently from the returned statement.  In case of error, Run returns the error code.  The context passed to
+// RunContext is used, both to prepare the statement and by the statement's methods that don't take a context.
+//
+// The caller must call the statement's Close method when the statement is no longer needed.
+func (conn *Conn) MustPrepare(query string) *Stmt {
+	return conn.MustPrepareContext(conn.Context(), query)
+}
+
+// MustPrepareContext creates a prepared statement for later queries or executions.  Multiple queries or executions
+// may be run concurrently from the returned statement.  In case of error, Run returns the error code.  The statement's
+// methods that don't take a context use the context passed to RunContext, not ctx.
+//
+// The caller must call the statement's Close method when the statement is no longer needed.
+func (conn *Conn) MustPrepareContext(ctx context.Context, query string) *Stmt {
+	return mustPrepare(ctx, nil, query, conn.ctx, func(ctx context.Context) (*sql.Stmt, error) {
+		return conn.PrepareContext(ctx, query)
+	})
+}
+
+// Fail aborts the function run by Run, returning the given error code to the caller of Run.
+func (conn *Conn) Fail(err error) {
+	panic(sxError{err})
+}
+
+// Context returns the context passed to RunContext.  The Must*** methods that don't take a context use this one.
+func (conn *Conn) Context() context.Context {
+	return orBackground(conn.ctx)
+}
+
+// Run runs the function f without a transaction.  Each query is run separately on db, which is typically an *sql.DB
+// or an *sql.Conn.  Within f, if Fail() is invoked or if any Must*** method encounters an error, then f is aborted and
+// Run returns the error.  If f runs to completion, then Run returns nil.
+//
+// As with Do, f should not attempt to recover from the panics raised by Fail() and the Must*** methods.  Any other
+// panic is propagated to the caller of Run.
+func Run(db Queryer, f func(*Conn)) error {
+	return RunContext(context.Background(), db, f)
+}
+
+// RunContext runs the function f without a transaction, like Run.  The context is used by the Must*** methods that
+// don't take a context.
+func RunContext(ctx context.Context, db Queryer, f func(*Conn)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if ourerr, ok := r.(sxError); ok {
+				// Our panic.  Unwrap it and return it as an error code.
+				err = ourerr.err
+			} else {
+				// Not our panic, so propagate it.
+				panic(r)
+			}
+		}
+	}()
+
+	f(&Conn{Queryer: db, ctx: ctx})
+	return nil
+}
@@ -0,0 +1,161 @@
+package sx_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+func TestRun(t *testing.T) {
+
+	t.Run("MustExec with result", func(t *testing.T) {
+		db, mock := newMock(t)
+		a, b := rand.Int63(), rand.Int63()
+		const query = "UPDATE alpha_run"
+
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(a, b))
+
+		err := sx.Run(db, func(conn *sx.Conn) {
+			res := conn.MustExec(query)
+			a0, _ := res.LastInsertId()
+			b0, _ := res.RowsAffected()
+			if a0 != a || b0 != b {
+				t.Errorf("Expected result (%d, %d), got (%d, %d)", a, b, a0, b0)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("MustExec with error", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "UPDATE bravo_run"
+		err0 := errors.New("bravo_run error")
+
+		mock.ExpectExec(query).WillReturnError(err0)
+
+		err := sx.Run(db, func(conn *sx.Conn) {
+			conn.MustExec(query)
+			t.Errorf("should not be reached")
+		})
+		if !errors.Is(err, err0) {
+			t.Errorf("expected error %v, got %v", err0, err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("MustQuery with 2 struct result rows", func(t *testing.T) {
+		type ab struct{ A, B int64 }
+
+		db, mock := newMock(t)
+		dat, x := [2]ab{{A: rand.Int63(), B: rand.Int63()}, {A: rand.Int63(), B: rand.Int63()}}, rand.Int63()
+		const query = "SELECT charlie_run"
+		rows := sqlmock.NewRows([]string{"a", "b"}).AddRow(dat[0].A, dat[0].B).AddRow(dat[1].A, dat[1].B)
+
+		mock.ExpectQuery(query).WithArgs(x).WillReturnRows(rows)
+
+		var res [2]ab
+		n := 0
+		err := sx.Run(db, func(conn *sx.Conn) {
+			conn.MustQuery(query, x).Each(func(r *sx.Rows) {
+				r.MustScans(&res[n])
+				n++
+			})
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if n != 2 || res != dat {
+			t.Errorf("Expected results %v, got %d rows %v", dat, n, res)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("MustQueryRow with no rows", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT delta_run"
+
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"a"}))
+
+		err := sx.Run(db, func(conn *sx.Conn) {
+			var a0 int64
+			conn.MustQueryRow(query).MustScan(&a0)
+		})
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("expected error %v, got %v", sql.ErrNoRows, err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("MustPrepare on a connection", func(t *testing.T) {
+		db, mock := newMock(t)
+		a, x := rand.Int63(), rand.Int63()
+		const query = "SELECT echo_run"
+		rows := sqlmock.NewRows([]string{"a"}).AddRow(a)
+
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(x).WillReturnRows(rows)
+
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer conn.Close()
+
+		err = sx.RunContext(context.Background(), conn, func(conn *sx.Conn) {
+			conn.MustPrepare(query).Do(func(stmt *sx.Stmt) {
+				var a0 int64
+				stmt.MustQueryRow(x).MustScan(&a0)
+				if a0 != a {
+					t.Errorf("Expected result %d, got %d", a, a0)
+				}
+			})
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("explicit fail", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("foxtrot_run error")
+
+		err := sx.Run(db, func(conn *sx.Conn) {
+			conn.Fail(err0)
+		})
+		if err != err0 {
+			t.Errorf("expected error %v, got %v", err0, err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("context is used", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "UPDATE golf_run"
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := sx.RunContext(ctx, db, func(conn *sx.Conn) {
+			conn.MustExec(query)
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected error %v, got %v", context.Canceled, err)
+		}
+
+		endMock(t, mock)
+	})
+}
@@ -10,6 +10,26 @@
 // Do automatically commits or rolls back the transaction based on whether or not the callback function completed
 // successfuly.
 //
+// The generic functions QueryAll, QueryOne and QueryOptional run a query and scan its rows into structs or scalar
+// values.
+//
+// Run is the non-transactional counterpart of Do.  It provides the callback function with a Conn object, which has
+// the same Must*** methods as Tx, and runs each query directly on the database or connection.
+//
+// DoWith is a variant of Do that accepts options, for example to re-run transactions that fail with a serialization
+// failure or a deadlock.
+//
+// The context of a transaction carries the transaction itself.  With the WithPropagation option, a DoWith called
+// with such a context can join the enclosing transaction, or run under a savepoint, instead of beginning a new one.
+// TxFromContext retrieves the transaction from a context.
+//
+// Package sxtest runs each test in a transaction that is always rolled back, and which the code under test joins.
+//
+// A Tracer can observe every transaction and statement, for instance to record spans or metrics.  Tracers are
+// registered globally with SetTracer, or for a single transaction with the WithTracer option.  SlowLog is a Tracer
+// that logs slow statements and transactions with log/slog.  The WithStats option collects counts and timings for
+// a single transaction.
+//
 // Query helpers and struct matching
 //
 // Package sx provides functions to generate frequently-used queries, based on a simple matching between struct
@@ -24,6 +44,20 @@
 //
 // Fields that should be used for scanning but exluded for inserts and updates are additionally tagged "readonly".
 //
+// The fields of an embedded struct, or of an embedded pointer to a struct, are treated as fields of the outer
+// struct, unless the embedded field is given a column name by a tag.  If several fields have the same column name,
+// then the least nested one is used, or if there are several, the only one tagged with the name, as in
+// encoding/json.  Embedded types that are scanned as a whole, such as time.Time and implementations of sql.Scanner,
+// are not flattened.  Nil pointers to embedded structs are allocated when scanning, are skipped by UpdateQuery, and
+// give NULL values otherwise.
+//
+// The fields of a named struct field, or pointer to a struct, tagged "prefix" are likewise treated as fields of the
+// outer struct, with column names prefixed by the column name of the struct field and an underscore.  They are
+// addressed by their paths, such as "Billing.City", in ValueOf, ColumnOf and UpdateFieldsQuery.
+//
+// MustScans scans the columns of a row into the fields of a struct in order, so the query must select the columns in
+// the order of the struct, as given by Columns.  MustScansByName instead matches the columns to the fields by name.
+//
 // Examples:
 //
 //     // Field is called "field" in the database.
@@ -40,4 +74,7 @@
 //
 //     // Field should be ignored by sx.
 //     Field int `sx:"-"`
+//
+//     // Fields of Address are called "billing_street", "billing_city" and so on in the database.
+//     Billing Address `sx:"billing,prefix"`
 package sx
@@ -0,0 +1,188 @@
+package sx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The operations recorded in a QueryError.
+const (
+	opExec    = "exec"
+	opQuery   = "query"
+	opPrepare = "prepare"
+	opScan    = "scan"
+)
+
+// A QueryError records a failed Must*** operation, along with the query involved and the location in the caller's
+// code.  The Must*** methods abort the transaction with a *QueryError, which wraps the error from database/sql or the
+// driver.  Use errors.Is or errors.As to examine the underlying error.
+type QueryError struct {
+	Op    string        // the operation that failed: "exec", "query", "prepare" or "scan"
+	Query string        // the SQL text of the query, if known
+	Args  []interface{} // the arguments of the query, as returned by the current Redactor
+	File  string        // the source file of the code that called sx
+	Line  int           // the line number of the code that called sx
+	Err   error         // the underlying error
+}
+
+// Error returns a description of the failed operation, including the query text but not the arguments.
+func (e *QueryError) Error() string {
+	bob := strings.Builder{}
+	bob.WriteString("sx: ")
+	bob.WriteString(e.Op)
+	if e.Query != "" {
+		bob.WriteByte(' ')
+		bob.WriteString(strconv.Quote(e.Query))
+	}
+	if e.File != "" {
+		bob.WriteString(" at ")
+		bob.WriteString(e.File)
+		bob.WriteByte(':')
+		bob.WriteString(strconv.Itoa(e.Line))
+	}
+	bob.WriteString(": ")
+	if e.Err != nil {
+		bob.WriteString(e.Err.Error())
+	} else {
+		bob.WriteString("<nil>")
+	}
+	return bob.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// A Redactor transforms the arguments of a query before they are recorded, for example to hide sensitive values.
+// A Redactor must not modify the slice that it is passed.
+type Redactor func(args []interface{}) []interface{}
+
+// RedactAll is a Redactor that replaces every argument with the string "[redacted]".
+func RedactAll(args []interface{}) []interface{} {
+	if args == nil {
+		return nil
+	}
+	redacted := make([]interface{}, len(args))
+	for i := range redacted {
+		redacted[i] = "[redacted]"
+	}
+	return redacted
+}
+
+var redactor Redactor
+
+// SetRedactor sets the Redactor applied to query arguments recorded in a QueryError.  If r is nil, which is the
+// default, then the arguments are recorded as they are.  This setting should be made during initialization.
+func SetRedactor(r Redactor) {
+	redactor = r
+}
+
+// redact applies the current Redactor to args.
+func redact(args []interface{}) []interface{} {
+	if redactor == nil {
+		return args
+	}
+	return redactor(args)
+}
+
+// queryFailed returns the panic value that aborts a transaction when a Must*** operation fails.
+func queryFailed(op, query string, args []interface{}, err error) sxError {
+	file, line := caller()
+	return sxError{&QueryError{
+		Op:    op,
+		Query: query,
+		Args:  redact(args),
+		File:  file,
+		Line:  line,
+		Err:   err,
+	}}
+}
+
+// The prefix of the names of all functions in this package, as reported by the runtime.
+var funcPrefix = reflect.TypeOf(Tx{}).PkgPath() + "."
+
+// caller returns the location of the innermost function on the stack that is outside of this package.
+func caller() (file string, line int) {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, funcPrefix) {
+			return frame.File, frame.Line
+		}
+		if !more {
+			return "", 0
+		}
+	}
+}
+
+// The limits reported in a TimeoutError.
+const (
+	LimitTransaction = "transaction" // the limit set by WithTimeout
+	LimitStatement   = "statement"   // the limit set by WithStatementTimeout
+)
+
+// A TimeoutError records that a transaction or a statement was aborted because it exceeded the time limit set by
+// WithTimeout or WithStatementTimeout.  A TimeoutError matches context.DeadlineExceeded with errors.Is.
+type TimeoutError struct {
+	Limit   string        // the limit that was exceeded: LimitTransaction or LimitStatement
+	Timeout time.Duration // the duration of the limit
+	Err     error         // the error reported by database/sql or the driver, if any
+}
+
+// Error returns a description of the limit that was exceeded.
+func (e *TimeoutError) Error() string {
+	msg := "sx: " + e.Limit + " timeout of " + e.Timeout.String() + " exceeded"
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is context.DeadlineExceeded.
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// timeoutError returns err, wrapped in a *TimeoutError if ctx has exceeded a deadline set by WithTimeout or
+// WithStatementTimeout.  Drivers report cancellation in various ways, so the context is checked rather than err.
+func timeoutError(ctx context.Context, err error) error {
+	var limit, already *TimeoutError
+	if ctx.Err() != context.DeadlineExceeded || !errors.As(context.Cause(ctx), &limit) || errors.As(err, &already) {
+		return err
+	}
+	return &TimeoutError{Limit: limit.Limit, Timeout: limit.Timeout, Err: err}
+}
+
+// A PanicError records a panic in a transaction's callback function.  With the WithPanicRecovery option, DoWith
+// returns a *PanicError in place of the panic.  OnRollback hooks are passed a *PanicError in either case.
+type PanicError struct {
+	Value interface{} // the value passed to panic
+	Stack []byte      // the stack trace of the panicking goroutine
+}
+
+// Error returns a description of the panic value.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("sx: panic in transaction: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error, and nil otherwise.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package sx_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+// here returns the line number of its caller.
+func here() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+func TestQueryError(t *testing.T) {
+
+	err0 := errors.New("query error")
+
+	var testCases = []struct {
+		name      string
+		setup     func(mock sqlmock.Sqlmock)
+		run       func(tx *sx.Tx, line *int) // runs the failing operation, after setting line to its line number
+		wantOp    string
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name: "MustExec",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE alpha").WithArgs(1, "a").WillReturnError(err0)
+			},
+			run: func(tx *sx.Tx, line *int) {
+				*line = here() + 1
+				tx.MustExec("UPDATE alpha", 1, "a")
+			},
+			wantOp:    "exec",
+			wantQuery: "UPDATE alpha",
+			wantArgs:  []interface{}{1, "a"},
+		},
+		{
+			name: "MustQuery",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT bravo").WithArgs(2).WillReturnError(err0)
+			},
+			run: func(tx *sx.Tx, line *int) {
+				*line = here() + 1
+				tx.MustQuery("SELECT bravo", 2)
+			},
+			wantOp:    "query",
+			wantQuery: "SELECT bravo",
+			wantArgs:  []interface{}{2},
+		},
+		{
+			name: "MustQueryRow",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT charlie").WithArgs(3).WillReturnError(err0)
+			},
+			run: func(tx *sx.Tx, line *int) {
+				var x int
+				*line = here() + 1
+				tx.MustQueryRow("SELECT charlie", 3).MustScan(&x)
+			},
+			wantOp:    "query",
+			wantQuery: "SELECT charlie",
+			wantArgs:  []interface{}{3},
+		},
+		{
+			name: "Row.MustScan",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT delta").WillReturnRows(sqlmock.NewRows([]string{"a"}).RowError(0, err0).AddRow(4))
+			},
+			run: func(tx *sx.Tx, line *int) {
+				var x int
+				*line = here() + 1
+				tx.MustQueryRow("SELECT delta").MustScan(&x)
+			},
+			wantOp:    "scan",
+			wantQuery: "SELECT delta",
+		},
+		{
+			name: "MustPrepare",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPrepare("SELECT echo").WillReturnError(err0)
+			},
+			run: func(tx *sx.Tx, line *int) {
+				*line = here() + 1
+				tx.MustPrepare("SELECT echo")
+			},
+			wantOp:    "prepare",
+			wantQuery: "SELECT echo",
+		},
+		{
+			name: "Stmt.MustExec",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPrepare("UPDATE foxtrot").ExpectExec().WithArgs(6).WillReturnError(err0)
+			},
+			run: func(tx *sx.Tx, line *int) {
+				tx.MustPrepare("UPDATE foxtrot").Do(func(stmt *sx.Stmt) {
+					*line = here() + 1
+					stmt.MustExec(6)
+				})
+			},
+			wantOp:    "exec",
+			wantQuery: "UPDATE foxtrot",
+			wantArgs:  []interface{}{6},
+		},
+		{
+			name: "Each",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT golf").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(1).RowError(0, err0))
+			},
+			run: func(tx *sx.Tx, line *int) {
+				*line = here() + 1
+				tx.MustQuery("SELECT golf", 7).Each(func(*sx.Rows) {})
+			},
+			wantOp:    "query",
+			wantQuery: "SELECT golf",
+			wantArgs:  []interface{}{7},
+		},
+		{
+			name: "Rows.MustScan",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT hotel").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("x"))
+			},
+			run: func(tx *sx.Tx, line *int) {
+				tx.MustQuery("SELECT hotel").Each(func(r *sx.Rows) {
+					var x int
+					*line = here() + 1
+					r.MustScan(&x)
+				})
+			},
+			wantOp:    "scan",
+			wantQuery: "SELECT hotel",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			db, mock := newMock(t)
+
+			mock.ExpectBegin()
+			c.setup(mock)
+			mock.ExpectRollback()
+
+			var line int
+			err := sx.DoContext(context.Background(), db, func(tx *sx.Tx) {
+				c.run(tx, &line)
+			})
+			var qerr *sx.QueryError
+			if !errors.As(err, &qerr) {
+				t.Fatalf("expected a *QueryError, got %v", err)
+			}
+			if a, b := c.wantOp, qerr.Op; a != b {
+				t.Errorf("expected op %q, got %q", a, b)
+			}
+			if a, b := c.wantQuery, qerr.Query; a != b {
+				t.Errorf("expected query %q, got %q", a, b)
+			}
+			if a, b := c.wantArgs, qerr.Args; len(a) != len(b) || (len(a) > 0 && a[0] != b[0]) {
+				t.Errorf("expected args %v, got %v", a, b)
+			}
+			if a, b := "errors_test.go", filepath.Base(qerr.File); a != b {
+				t.Errorf("expected file %q, got %q", a, b)
+			}
+			if a, b := line, qerr.Line; a != b {
+				t.Errorf("expected line %d, got %d", a, b)
+			}
+
+			endMock(t, mock)
+		})
+	}
+}
+
+func TestQueryErrorUnwrap(t *testing.T) {
+	err0 := errors.New("india error")
+	err := &sx.QueryError{Op: "exec", Query: "SELECT india", File: "india.go", Line: 12, Err: err0}
+	if !errors.Is(err, err0) {
+		t.Errorf("expected error to wrap %v", err0)
+	}
+	if a, b := `sx: exec "SELECT india" at india.go:12: india error`, err.Error(); a != b {
+		t.Errorf("expected %q, got %q", a, b)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	defer sx.SetRedactor(nil)
+	sx.SetRedactor(sx.RedactAll)
+
+	db, mock := newMock(t)
+	const query = "UPDATE juliett"
+	err0 := errors.New("juliett error")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(query).WithArgs("secret", 42).WillReturnError(err0)
+	mock.ExpectRollback()
+
+	err := sx.Do(db, func(tx *sx.Tx) {
+		tx.MustExec(query, "secret", 42)
+	})
+	var qerr *sx.QueryError
+	if !errors.As(err, &qerr) {
+		t.Fatalf("expected a *QueryError, got %v", err)
+	}
+	if len(qerr.Args) != 2 || qerr.Args[0] != "[redacted]" || qerr.Args[1] != "[redacted]" {
+		t.Errorf("expected redacted args, got %v", qerr.Args)
+	}
+
+	endMock(t, mock)
+}
@@ -1,6 +1,7 @@
 package sx_test
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -172,3 +173,31 @@ func ExampleUpdateFieldsQuery() {
 	// UPDATE sometable SET bar=$2,baz=$3 WHERE id=$1
 	// [Goodbye 42]
 }
+
+func ExampleDoValue() {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	_, err = db.Exec("CREATE TABLE numbers (foo integer); INSERT INTO numbers VALUES (1), (2), (3)")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Use DoValue to return a result from the transaction.
+	total, err := sx.DoValue(context.Background(), db, func(tx *sx.Tx) int64 {
+		var total int64
+		tx.MustQueryRow("SELECT sum(foo) FROM numbers").MustScan(&total)
+		return total
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(total)
+	// Output:
+	// 6
+}
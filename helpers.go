@@ -165,7 +165,7 @@ func UpdateQuery(table string, data interface{}, ph ...*Placeholder) (string, []
 
 	for _, c := range m.columns {
 		if !c.readonly {
-			if val := instance.Field(c.index); !val.IsZero() {
+			if val, ok := c.fieldOf(instance); ok && !val.IsZero() {
 				columns = append(columns, c.name+"="+p.Next())
 				if val.Kind() == reflect.Ptr {
 					val = val.Elem()
@@ -220,6 +220,8 @@ func UpdateAllQuery(table string, data interface{}) string {
 //
 // With numbered placeholders, numbering starts at $2.  This allows $1 to be used in the WHERE clause.
 //
+// Fields of embedded structs and of structs tagged "prefix" may be given as with ValueOf.
+//
 // UpdateFieldsQuery panics if no field names are provided or if any of the requested fields do not exist.  If it is
 // necessary to validate field names, use ColumnOf.
 func UpdateFieldsQuery(table string, data interface{}, fields ...string) (string, []interface{}) {
@@ -236,7 +238,7 @@ func UpdateFieldsQuery(table string, data interface{}, fields ...string) (string
 	for _, field := range fields {
 		if c, ok := m.columnMap[field]; ok {
 			columns = append(columns, c.name+"="+p.Next())
-			values = append(values, instance.Field(c.index).Interface())
+			values = append(values, c.valueOf(instance))
 		} else {
 			panic("struct " + m.reflectType.Name() + " has no usable field " + field)
 		}
@@ -254,7 +256,7 @@ func Addrs(dest interface{}) []interface{} {
 	val := reflect.ValueOf(dest).Elem()
 	addrs := make([]interface{}, 0, len(m.columns))
 	for _, c := range m.columns {
-		addrs = append(addrs, val.Field(c.index).Addr().Interface())
+		addrs = append(addrs, c.addrOf(val))
 	}
 	return addrs
 }
@@ -269,19 +271,21 @@ func Values(data interface{}) []interface{} {
 	values := make([]interface{}, 0, len(m.columns))
 	for _, c := range m.columns {
 		if !c.readonly {
-			values = append(values, val.Field(c.index).Interface())
+			values = append(values, c.valueOf(val))
 		}
 	}
 	return values
 }
 
-// ValueOf returns the value of the specified field of the struct pointed at by data.  Panics if data does not
-// point at a struct, or if the requested field doesn't exist.
+// ValueOf returns the value of the specified field of the struct pointed at by data.  The field of an embedded struct
+// may be given by its promoted name, or by its path, such as "Audit.CreatedAt", and the field of a struct tagged
+// "prefix" by its path, such as "Billing.City".  If the field is reached through a nil pointer, then ValueOf returns
+// nil.  Panics if data does not point at a struct, or if the requested field doesn't exist.
 func ValueOf(data interface{}, field string) interface{} {
 	// This step verifies data and field and might panic.
 	c := matchingOf(data).columnOf(field)
 	// If there is a panic, then the reflection here will not be attempted.
-	return reflect.ValueOf(data).Elem().Field(c.index).Interface()
+	return c.valueOf(reflect.ValueOf(data).Elem())
 }
 
 // Columns returns the names of the database columns that correspond to the fields in the struct pointed at by
@@ -297,7 +301,7 @@ func ColumnsWriteable(datatype interface{}) []string {
 }
 
 // ColumnOf returns the name of the database column that corresponds to the specified field of the struct pointed
-// at by datatype.
+// at by datatype.  Fields of embedded structs and of structs tagged "prefix" may be given as with ValueOf.
 //
 // ColumnOf returns an error if the provided field name is missing from the struct.
 func ColumnOf(datatype interface{}, field string) (string, error) {
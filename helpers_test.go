@@ -648,3 +648,172 @@ func TestColumnOf(t *testing.T) {
 		}
 	}
 }
+
+type audit struct {
+	ID        int64 `sx:",readonly"`
+	CreatedAt string
+	UpdatedAt string
+}
+
+type Keeper struct {
+	Name string `sx:"keeper"`
+	ID   int64  `sx:"keeper_id"`
+}
+
+type menagerie2 struct {
+	ID   int64 `sx:",readonly"`
+	Name string
+	audit
+	*Keeper
+	Tag        string
+	Duplicated struct{ X int } `sx:"dup"`
+}
+
+type labels struct {
+	Tag string
+}
+
+type menagerie3 struct {
+	menagerie2
+	labels `sx:",readonly"`
+}
+
+func TestEmbedded(t *testing.T) {
+
+	t.Run("columns of embedded structs", func(t *testing.T) {
+		// ID in audit is shadowed by ID in menagerie2, and the Tags in menagerie2 and labels are both at depth 2 in
+		// menagerie3, so neither is used.
+		var testCases = []struct {
+			datatype      interface{}
+			wantColumns   []string
+			wantWriteable []string
+		}{
+			{
+				datatype:      &menagerie2{},
+				wantColumns:   []string{"id", "name", "created_at", "updated_at", "keeper", "keeper_id", "tag", "dup"},
+				wantWriteable: []string{"name", "created_at", "updated_at", "keeper", "keeper_id", "tag", "dup"},
+			},
+			{
+				datatype:      &menagerie3{},
+				wantColumns:   []string{"id", "name", "created_at", "updated_at", "keeper", "keeper_id", "dup"},
+				wantWriteable: []string{"name", "created_at", "updated_at", "keeper", "keeper_id", "dup"},
+			},
+		}
+		for _, c := range testCases {
+			if got := sx.Columns(c.datatype); !reflect.DeepEqual(got, c.wantColumns) {
+				t.Errorf("expected columns %v, got %v", c.wantColumns, got)
+			}
+			if got := sx.ColumnsWriteable(c.datatype); !reflect.DeepEqual(got, c.wantWriteable) {
+				t.Errorf("expected writeable columns %v, got %v", c.wantWriteable, got)
+			}
+		}
+		if got := sx.SelectQuery("zoo", &menagerie2{}); got !=
+			"SELECT id,name,created_at,updated_at,keeper,keeper_id,tag,dup FROM zoo" {
+			t.Errorf("unexpected select query %q", got)
+		}
+	})
+
+	t.Run("Addrs and Values with a nil embedded pointer", func(t *testing.T) {
+		// The embedded pointer is nil, so its fields give nil values.
+		x := &menagerie2{}
+		if got := sx.Values(x); !reflect.DeepEqual(got, []interface{}{"", "", "", nil, nil, "", struct{ X int }{}}) {
+			t.Errorf("unexpected values %#v", got)
+		}
+		addrs := sx.Addrs(x)
+		if x.Keeper == nil {
+			t.Fatalf("expected Addrs to allocate the embedded pointer")
+		}
+		*addrs[2].(*string) = "yesterday"
+		*addrs[5].(*int64) = 7
+		if x.CreatedAt != "yesterday" || x.Keeper.ID != 7 {
+			t.Errorf("unexpected struct %+v", x)
+		}
+	})
+
+	t.Run("promoted field names", func(t *testing.T) {
+		sx.SetNumberedPlaceholders(false)
+		x := &menagerie2{
+			ID:     1,
+			Name:   "zebra",
+			audit:  audit{ID: 2, CreatedAt: "monday"},
+			Keeper: &Keeper{Name: "alice", ID: 3},
+		}
+		if got := sx.ValueOf(x, "ID"); got != int64(1) {
+			t.Errorf("expected 1, got %v", got)
+		}
+		if got := sx.ValueOf(x, "CreatedAt"); got != "monday" {
+			t.Errorf("expected monday, got %v", got)
+		}
+		if got := sx.ValueOf(x, "audit.CreatedAt"); got != "monday" {
+			t.Errorf("expected monday, got %v", got)
+		}
+		if got, err := sx.ColumnOf(x, "Keeper.ID"); err != nil || got != "keeper_id" {
+			t.Errorf("expected keeper_id, got %q, %v", got, err)
+		}
+		if _, err := sx.ColumnOf(x, "audit.ID"); err == nil {
+			t.Errorf("expected an error for a shadowed field")
+		}
+		query, values := sx.UpdateFieldsQuery("zoo", x, "CreatedAt", "Keeper.Name", "Name")
+		if query != "UPDATE zoo SET created_at=?,keeper=?,name=?" ||
+			!reflect.DeepEqual(values, []interface{}{"monday", "alice", "zebra"}) {
+			t.Errorf("unexpected query %q with values %v", query, values)
+		}
+		query, values = sx.UpdateQuery("zoo", &menagerie2{Name: "bob", audit: audit{UpdatedAt: "tuesday"}})
+		if query != "UPDATE zoo SET name=?,updated_at=?" || !reflect.DeepEqual(values, []interface{}{"bob", "tuesday"}) {
+			t.Errorf("unexpected query %q with values %v", query, values)
+		}
+	})
+}
+
+type address struct {
+	Street string
+	City   string `sx:"town"`
+}
+
+type menagerie4 struct {
+	ID       int64    `sx:",readonly"`
+	Billing  address  `sx:"billing,prefix"`
+	Shipping *address `sx:",prefix"`
+	Home     address
+}
+
+func TestPrefix(t *testing.T) {
+
+	t.Run("columns of prefixed structs", func(t *testing.T) {
+		expected := []string{"id", "billing_street", "billing_town", "shipping_street", "shipping_town", "home"}
+		if got := sx.Columns(&menagerie4{}); !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected columns %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Addrs and Values with a nil prefixed pointer", func(t *testing.T) {
+		x := &menagerie4{Billing: address{"Main Street", "Springfield"}}
+		if got := sx.Values(x); !reflect.DeepEqual(got, []interface{}{"Main Street", "Springfield", nil, nil, address{}}) {
+			t.Errorf("unexpected values %#v", got)
+		}
+		addrs := sx.Addrs(x)
+		*addrs[4].(*string) = "Shelbyville"
+		if x.Shipping == nil || x.Shipping.City != "Shelbyville" {
+			t.Errorf("unexpected struct %+v", x)
+		}
+	})
+
+	t.Run("field paths", func(t *testing.T) {
+		sx.SetNumberedPlaceholders(false)
+		x := &menagerie4{ID: 1, Billing: address{"Main Street", "Springfield"}}
+		if got := sx.ValueOf(x, "Billing.City"); got != "Springfield" {
+			t.Errorf("expected Springfield, got %v", got)
+		}
+		if got, err := sx.ColumnOf(x, "Shipping.City"); err != nil || got != "shipping_town" {
+			t.Errorf("expected shipping_town, got %q, %v", got, err)
+		}
+		if _, err := sx.ColumnOf(x, "City"); err == nil {
+			t.Errorf("expected an error for a field without its path")
+		}
+		query, values := sx.UpdateFieldsQuery("zoo", x, "Billing.City", "Billing.Street")
+		if query != "UPDATE zoo SET billing_town=?,billing_street=?" ||
+			!reflect.DeepEqual(values, []interface{}{"Springfield", "Main Street"}) {
+			t.Errorf("unexpected query %q with values %v", query, values)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package sx
+
+import "iter"
+
+// All returns an iterator over the rows in a result set, for use with a range loop:
+//
+//	for r := range tx.MustQuery(query).All() {
+//		r.MustScan(&x)
+//		...
+//	}
+//
+// The rows are closed when the loop ends, including when it is left early with break or return.  If the context of
+// the query is cancelled or its deadline expires, or if reading the rows fails, then the iteration stops, the
+// transaction is aborted and Do returns the error code.
+func (rows *Rows) All() iter.Seq[*Rows] {
+	return func(yield func(*Rows) bool) {
+		defer rows.Close()
+		ctx := orBackground(rows.ctx)
+		for rows.Next() {
+			if err := ctx.Err(); err != nil {
+				err = timeoutError(ctx, err)
+				rows.failed(err)
+				rows.cfg.fail(queryFailed(opQuery, rows.query, rows.args, err))
+			}
+			if !yield(rows) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			err = timeoutError(ctx, err)
+			rows.failed(err)
+			rows.cfg.fail(queryFailed(opQuery, rows.query, rows.args, err))
+		}
+	}
+}
+
+// Seq returns an iterator over the rows in a result set, each scanned into a T as with QueryAll.  The rows are
+// closed when the loop ends, including when it is left early.  In case of error, the iteration stops, the
+// transaction is aborted and Do returns the error code.
+func Seq[T any](rows *Rows) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for r := range rows.All() {
+			var x T
+			mustScanRow(r, &x)
+			if !yield(x) {
+				return
+			}
+		}
+	}
+}
+
+// Seq2 is like Seq, but instead of aborting the transaction in case of error, it yields the error, which is the
+// same *QueryError with which Seq would abort the transaction, and then stops.  Each row is yielded with a nil error.
+func Seq2[T any](rows *Rows) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer rows.Close()
+		ctx := orBackground(rows.ctx)
+		failed := func(op string, err error) {
+			err = timeoutError(ctx, err)
+			rows.failed(err)
+			var zero T
+			yield(zero, queryFailed(op, rows.query, rows.args, err).err)
+		}
+		for rows.Next() {
+			if err := ctx.Err(); err != nil {
+				failed(opQuery, err)
+				return
+			}
+			var x T
+			var err error
+			if scansAsStruct[T]() {
+				err = rows.validate(&x)
+			}
+			if err == nil {
+				err = rows.Scan(scanDest(&x)...)
+			}
+			if err != nil {
+				failed(opScan, err)
+				return
+			}
+			if !yield(x, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			failed(opQuery, err)
+		}
+	}
+}
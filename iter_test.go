@@ -0,0 +1,150 @@
+package sx_test
+
+import (
+	"errors"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+func TestIterators(t *testing.T) {
+
+	type ab struct {
+		Alpha int
+		Bravo string
+	}
+
+	t.Run("All with break", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT alpha FROM ab"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WillReturnRows(sqlmock.NewRows([]string{"alpha"}).AddRow(1).AddRow(2).AddRow(3)).RowsWillBeClosed()
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			var got []int
+			for r := range tx.MustQuery(query).All() {
+				var a int
+				r.MustScan(&a)
+				got = append(got, a)
+				if a == 2 {
+					break
+				}
+			}
+			if len(got) != 2 {
+				t.Errorf("expected 2 rows, got %v", got)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("Seq with structs and scalars", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT alpha,bravo FROM ab"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WillReturnRows(sqlmock.NewRows([]string{"alpha", "bravo"}).AddRow(1, "a").AddRow(2, "b")).RowsWillBeClosed()
+		mock.ExpectQuery("SELECT bravo FROM ab").
+			WillReturnRows(sqlmock.NewRows([]string{"bravo"}).AddRow("a").AddRow("b")).RowsWillBeClosed()
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			var got []ab
+			for x := range sx.Seq[ab](tx.MustQuery(query)) {
+				got = append(got, x)
+			}
+			if len(got) != 2 || got[0] != (ab{1, "a"}) || got[1] != (ab{2, "b"}) {
+				t.Errorf("unexpected rows %v", got)
+			}
+			for s := range sx.Seq[string](tx.MustQuery("SELECT bravo FROM ab")) {
+				if s != "a" {
+					t.Errorf("expected a, got %s", s)
+				}
+				break
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("Seq with row error", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT alpha,bravo FROM ab"
+		err0 := errors.New("charlie error")
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WillReturnRows(sqlmock.NewRows([]string{"alpha", "bravo"}).AddRow(1, "a").AddRow(2, "b").RowError(1, err0))
+		mock.ExpectRollback()
+
+		n := 0
+		err := sx.Do(db, func(tx *sx.Tx) {
+			for range sx.Seq[ab](tx.MustQuery(query)) {
+				n++
+			}
+			t.Errorf("expected the transaction to be aborted")
+		})
+		var qerr *sx.QueryError
+		if !errors.Is(err, err0) || !errors.As(err, &qerr) || qerr.Op != "query" {
+			t.Errorf("expected a query error wrapping %v, got %v", err0, err)
+		}
+		if n != 1 {
+			t.Errorf("expected 1 row before the error, got %d", n)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("Seq2 with row and scan errors", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT alpha,bravo FROM ab"
+		err0 := errors.New("delta error")
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WillReturnRows(sqlmock.NewRows([]string{"alpha", "bravo"}).AddRow(1, "a").AddRow(2, "b").RowError(1, err0)).
+			RowsWillBeClosed()
+		mock.ExpectQuery(query).
+			WillReturnRows(sqlmock.NewRows([]string{"alpha", "bravo"}).AddRow("x", "a"))
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			var got []ab
+			var errs []error
+			for x, err := range sx.Seq2[ab](tx.MustQuery(query)) {
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				got = append(got, x)
+			}
+			if len(got) != 1 || len(errs) != 1 || !errors.Is(errs[0], err0) {
+				t.Errorf("expected 1 row and then %v, got %v and %v", err0, got, errs)
+			}
+
+			for _, err := range sx.Seq2[ab](tx.MustQuery(query)) {
+				var qerr *sx.QueryError
+				if !errors.As(err, &qerr) || qerr.Op != "scan" {
+					t.Errorf("expected a scan error, got %v", err)
+				}
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+}
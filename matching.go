@@ -3,6 +3,7 @@ package sx
 import (
 	"reflect"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -15,9 +16,43 @@ type matching struct {
 }
 
 type column struct {
-	index    int    // index of this field in the struct
+	index    []int  // index sequence of this field in the struct, as for reflect.Value.FieldByIndex
 	name     string // name of the corresponding db column
 	readonly bool   // flag to skip this column on insert/update operations (e.g. for primary key or automatic timestamp)
+	field    string // dotted path by which the field is addressed, leaving out embedded structs, e.g. "Billing.City"
+	path     string // dotted path to the field, including embedded structs, e.g. "Billing.Address.City"
+	tagged   bool   // whether the column name was given by a tag, for resolving name conflicts
+}
+
+// fieldOf returns the field of column c in the struct val.  ok is false if the field is reached through a nil
+// pointer to an embedded struct.
+func (c *column) fieldOf(val reflect.Value) (field reflect.Value, ok bool) {
+	field, err := val.FieldByIndexErr(c.index)
+	return field, err == nil
+}
+
+// valueOf returns the value of the field of column c in the struct val, or nil if the field is reached through a nil
+// pointer to an embedded struct.
+func (c *column) valueOf(val reflect.Value) interface{} {
+	if field, ok := c.fieldOf(val); ok {
+		return field.Interface()
+	}
+	return nil
+}
+
+// addrOf returns a pointer to the field of column c in the struct val, allocating any nil pointers to embedded
+// structs on the way.
+func (c *column) addrOf(val reflect.Value) interface{} {
+	for _, i := range c.index[:len(c.index)-1] {
+		val = val.Field(i)
+		if val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+	}
+	return val.Field(c.index[len(c.index)-1]).Addr().Interface()
 }
 
 // ColumnList returns the names of the database columns in the order of the struct.
@@ -49,7 +84,8 @@ func (m *matching) columnOf(field string) *column {
 }
 
 // MatchingOf returns a matching for the given struct type, generating it if necessary.  MatchingOf looks only at the
-// structure of datatype and ignore its values.
+// structure of datatype and ignore its values.  The fields of embedded structs are flattened into the matching, as
+// described in the package documentation.
 //
 // Panics if datatype does not point at a struct, or if the struct has no usable fields.
 func matchingOf(datatype interface{}) *matching {
@@ -68,33 +104,27 @@ func matchingOf(datatype interface{}) *matching {
 	}
 
 	// Nothing cached, generate a new matching and cache it.
-	n := reflectType.NumField()
-	cols := make([]*column, 0)
+	cols := resolveConflicts(collectColumns(reflectType, nesting{visiting: []reflect.Type{reflectType}}))
 	colmap := make(map[string]*column)
-	for i := 0; i < n; i++ {
-		field := reflectType.Field(i)
-		tags := strings.Split(field.Tag.Get("sx"), ",")
-		colname := tags[0]
-		if colname == "-" || field.PkgPath != "" {
-			continue // skip excluded and unexported fields.
-		}
-		if colname == "" {
-			colname = snakeCase(field.Name) // default column name based on field name
+	depth := make(map[string]int)
+	for _, col := range cols {
+		colmap[col.path] = col
+		// A promoted field is also known by its own name, unless a less nested field has the same name.  If
+		// several fields at the same depth have the name, then it is ambiguous.
+		d, seen := depth[col.field]
+		switch {
+		case seen && d < len(col.index):
+		case seen && d == len(col.index):
+			colmap[col.field] = nil
+		default:
+			depth[col.field] = len(col.index)
+			colmap[col.field] = col
 		}
-		col := &column{
-			index: i,
-			name:  colname,
-		}
-		// See if there's a readonly tag.  A readonly tag would have to be in at least the second position, since
-		// the first position is always interpreted as a column name.
-		for _, tag := range tags[1:] {
-			if tag == "readonly" {
-				col.readonly = true
-				break
-			}
+	}
+	for field, col := range colmap {
+		if col == nil {
+			delete(colmap, field)
 		}
-		cols = append(cols, col)
-		colmap[field.Name] = col
 	}
 	if len(cols) == 0 {
 		panic("sx: struct " + reflectType.Name() + " has no usable fields")
@@ -109,6 +139,163 @@ func matchingOf(datatype interface{}) *matching {
 	return m
 }
 
+// A nesting locates a struct whose fields are inlined into the matching of an outer struct.
+type nesting struct {
+	index    []int          // index sequence of the struct in the outer struct
+	path     string         // dotted path to the struct, ending in a dot, or "" for the outer struct itself
+	field    string         // as path, but leaving out embedded structs, whose fields are promoted
+	prefix   string         // prefix of the names of the struct's columns
+	visiting []reflect.Type // the struct types being inlined, including this one, to guard against cycles
+}
+
+// inline returns the nesting of the struct type t held in field i of the struct at n.
+func (n nesting) inline(i int, field reflect.StructField, t reflect.Type, prefix string) nesting {
+	inner := nesting{
+		index:    append(append([]int(nil), n.index...), i),
+		path:     n.path + field.Name + ".",
+		field:    n.field,
+		prefix:   n.prefix + prefix,
+		visiting: append(append([]reflect.Type(nil), n.visiting...), t),
+	}
+	if !field.Anonymous {
+		inner.field += field.Name + "."
+	}
+	return inner
+}
+
+// collectColumns lists the candidate columns for the fields of the struct type t at n, inlining embedded structs
+// and structs tagged "prefix".
+func collectColumns(t reflect.Type, n nesting) []*column {
+	var cols []*column
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tags := strings.Split(field.Tag.Get("sx"), ",")
+		colname := tags[0]
+		if colname == "-" {
+			continue // skip excluded fields.
+		}
+		// See if there are readonly or prefix tags.  These would have to be in at least the second position, since
+		// the first position is always interpreted as a column name.
+		readonly, prefix := false, false
+		for _, tag := range tags[1:] {
+			switch tag {
+			case "readonly":
+				readonly = true
+			case "prefix":
+				prefix = true
+			}
+		}
+		if inner := inlinedStruct(field, colname, prefix); inner != nil {
+			// A readonly tag applies to all of the fields of an inlined struct.
+			if slices.Contains(n.visiting, inner) {
+				continue
+			}
+			var p string
+			if prefix {
+				if colname == "" {
+					colname = snakeCase(field.Name)
+				}
+				p = colname + "_"
+			}
+			inlined := collectColumns(inner, n.inline(i, field, inner, p))
+			for _, col := range inlined {
+				col.readonly = col.readonly || readonly
+			}
+			cols = append(cols, inlined...)
+			continue
+		}
+		if !field.IsExported() {
+			continue // skip unexported fields.
+		}
+		col := &column{
+			index:    append(append([]int(nil), n.index...), i),
+			name:     n.prefix + colname,
+			readonly: readonly,
+			field:    n.field + field.Name,
+			path:     n.path + field.Name,
+			tagged:   colname != "",
+		}
+		if colname == "" {
+			col.name = n.prefix + snakeCase(field.Name) // default column name based on field name
+		}
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+// inlinedStruct returns the struct type whose fields are to be inlined in place of field, or nil if the field is a
+// column in its own right.  The fields of an embedded struct, or pointer to a struct, are inlined if it has no column
+// name or is tagged "prefix", and so are those of an exported struct field tagged "prefix".  Types that are scanned as
+// a whole are not inlined, nor are pointers to unexported struct types, since they can't be allocated.
+func inlinedStruct(field reflect.StructField, colname string, prefix bool) reflect.Type {
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		if !field.IsExported() {
+			return nil
+		}
+	}
+	if t.Kind() != reflect.Struct || scansAsWhole(t) {
+		return nil
+	}
+	if field.Anonymous && (colname == "" || prefix) || field.IsExported() && prefix {
+		return t
+	}
+	return nil
+}
+
+// resolveConflicts removes the columns whose names conflict, following the rules of encoding/json: of the columns
+// with the same name, the least nested one is kept, or if there are several, the only one whose name was given by a
+// tag.  If neither rule gives a single column, then all of them are removed.  The columns remain in the order of
+// the struct.
+func resolveConflicts(cols []*column) []*column {
+	byName := make(map[string][]*column)
+	for _, col := range cols {
+		byName[col.name] = append(byName[col.name], col)
+	}
+	kept := make([]*column, 0, len(cols))
+	for _, col := range cols {
+		if dominant(byName[col.name]) == col {
+			kept = append(kept, col)
+		}
+	}
+	return kept
+}
+
+// dominant returns the column that wins among columns with the same name, or nil if there is none.
+func dominant(cols []*column) *column {
+	depth := len(cols[0].index)
+	for _, col := range cols[1:] {
+		depth = min(depth, len(col.index))
+	}
+	var shallowest []*column
+	for _, col := range cols {
+		if len(col.index) == depth {
+			shallowest = append(shallowest, col)
+		}
+	}
+	if len(shallowest) == 1 {
+		return shallowest[0]
+	}
+	var winner *column
+	for _, col := range shallowest {
+		if col.tagged {
+			if winner != nil {
+				return nil
+			}
+			winner = col
+		}
+	}
+	return winner
+}
+
+// scansAsWhole reports whether values of the struct type t are scanned and stored as a whole, rather than field by
+// field, as are time.Time and types that implement sql.Scanner or driver.Valuer.
+func scansAsWhole(t reflect.Type) bool {
+	return t == timeType || reflect.PointerTo(t).Implements(scannerType) || t.Implements(valuerType) ||
+		reflect.PointerTo(t).Implements(valuerType)
+}
+
 // Cache to keep track of struct types that have been seen and therefore analyzed.
 var matchingCache = make(map[reflect.Type]*matching)
 var matchingCacheMu sync.Mutex
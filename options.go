@@ -0,0 +1,159 @@
+package sx
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// An Option adjusts the way in which DoWith runs a transaction.
+type Option func(*config)
+
+// A config holds the settings collected from a list of options.
+type config struct {
+	txOptions        *sql.TxOptions
+	retry            *RetryPolicy
+	recoverPanics    bool
+	timeout          time.Duration // limit on the duration of the whole transaction
+	statementTimeout time.Duration // default limit on the duration of each statement
+	tracers          []Tracer      // tracers for this transaction, in addition to the global one
+	stats            *Stats        // where to record the commit time, if set
+	stmtCache        bool          // whether to prepare and cache the statements run by the Must*** methods
+	propagation      *Propagation  // what to do if the context already carries a transaction, if set
+	failHook         func(error)   // called before the transaction is aborted by a failure, if set
+	failShield       int           // number of enclosing Try or joined DoWith calls, during which failHook is not called
+	strictColumns    bool          // whether MustScansByName rejects result columns which match no field
+	validateColumns  bool          // whether MustScans checks the result columns against the struct
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// statementContext returns the context in which to run a single statement, applying the statement timeout if one
+// is set.  The returned cancel function must be called once the statement has finished.  cfg may be nil.
+func (cfg *config) statementContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if cfg == nil || cfg.statementTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, cfg.statementTimeout, &TimeoutError{
+		Limit:   LimitStatement,
+		Timeout: cfg.statementTimeout,
+	})
+}
+
+// WithTxOptions specifies the isolation level and/or read-only status of the transaction.  Without this option, the
+// default options are used.
+func WithTxOptions(opts sql.TxOptions) Option {
+	return func(cfg *config) {
+		cfg.txOptions = &opts
+	}
+}
+
+// WithRetry causes the transaction to be re-run from the beginning, in a fresh transaction, whenever it fails with an
+// error that the policy classifies as retryable.  See RetryPolicy.
+func WithRetry(policy RetryPolicy) Option {
+	return func(cfg *config) {
+		cfg.retry = &policy
+	}
+}
+
+// WithPanicRecovery causes panics in the callback function, other than those raised by Fail and the Must*** methods,
+// to be returned as a *PanicError instead of being propagated.  The transaction is rolled back in either case.
+func WithPanicRecovery() Option {
+	return func(cfg *config) {
+		cfg.recoverPanics = true
+	}
+}
+
+// WithTimeout limits the duration of the transaction, from the start of the transaction to the end of the commit.
+// When the limit is reached, the transaction's context is cancelled, and Do returns a *TimeoutError.  With WithRetry,
+// the limit applies to each attempt separately.
+func WithTimeout(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.timeout = d
+	}
+}
+
+// WithStatementTimeout limits the duration of each statement run by the Must*** methods of the transaction and of the
+// Stmt and Rows objects created from it, including statements that are given an explicit context.  For queries, the
+// limit includes the time taken to read the rows.  When the limit is reached, the transaction is aborted, and Do
+// returns a *TimeoutError.
+func WithStatementTimeout(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.statementTimeout = d
+	}
+}
+
+// WithTracer registers a Tracer for the transaction and the statements run within it.  The option may be given more
+// than once to register several tracers, which are called in the order given.  See Tracer.
+func WithTracer(t Tracer) Option {
+	return func(cfg *config) {
+		cfg.tracers = append(cfg.tracers, t)
+	}
+}
+
+// WithFailHook registers a function to be called whenever a Must*** method fails, or Fail is called, just before the
+// transaction is aborted.  h is called in the goroutine that made the failing call, with the error that Do would
+// return.  It is not called for failures that are caught within the transaction, by Try or by a joined DoWith.
+//
+// The hook is meant for test harnesses such as package sxtest, where it may call t.Fatal.  If h does not return, the
+// transaction is left open, and it is up to the caller to end it.
+func WithFailHook(h func(err error)) Option {
+	return func(cfg *config) {
+		cfg.failHook = h
+	}
+}
+
+// WithStrictColumns causes MustScansByName to abort the transaction when a result column matches no field of the
+// struct, instead of discarding the column.
+func WithStrictColumns() Option {
+	return func(cfg *config) {
+		cfg.strictColumns = true
+	}
+}
+
+// strictColumnsSet reports whether WithStrictColumns was given.  cfg may be nil.
+func (cfg *config) strictColumnsSet() bool {
+	return cfg != nil && cfg.strictColumns
+}
+
+// WithColumnValidation causes MustScans, and QueryAll and the other generic helpers when scanning into structs, to
+// check the result columns of each query against the fields of the struct before scanning the first row.  If the
+// columns are not those of the struct, in the same order, then the transaction is aborted with a
+// *ColumnMismatchError, which names the missing, extra and misordered columns.  See ValidateColumns.  Row.MustScans
+// is not checked, since sql.Row does not report its columns.
+func WithColumnValidation() Option {
+	return func(cfg *config) {
+		cfg.validateColumns = true
+	}
+}
+
+// validateColumnsSet reports whether WithColumnValidation was given.  cfg may be nil.
+func (cfg *config) validateColumnsSet() bool {
+	return cfg != nil && cfg.validateColumns
+}
+
+// fail calls the fail hook, if any, and then aborts the transaction by panicking with e.  cfg may be nil.
+func (cfg *config) fail(e sxError) {
+	if cfg != nil && cfg.failHook != nil && cfg.failShield == 0 {
+		cfg.failHook(e.err)
+	}
+	panic(e)
+}
+
+// runShielded runs f without calling the fail hook, for failures that will be caught within the transaction, by Try
+// or by a joined DoWith.
+func (tx *Tx) runShielded(f func(*Tx)) {
+	if cfg := tx.cfg; cfg != nil {
+		cfg.failShield++
+		defer func() {
+			cfg.failShield--
+		}()
+	}
+	f(tx)
+}
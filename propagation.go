@@ -0,0 +1,115 @@
+package sx
+
+import (
+	"context"
+	"errors"
+)
+
+// A Propagation determines how DoWith behaves when its context already carries a transaction, as it does within the
+// callback function of another DoWith.  It is set with the WithPropagation option.
+type Propagation int
+
+const (
+	// PropagationRequiresNew always begins a new transaction, independent of any transaction in the context.  This
+	// is the default, unless the context says otherwise (see ContextWithPropagation).
+	PropagationRequiresNew Propagation = iota
+
+	// PropagationRequired joins the transaction in the context, if there is one, and otherwise begins a new
+	// transaction.
+	PropagationRequired
+
+	// PropagationNested runs the callback function under a savepoint of the transaction in the context, as with
+	// Tx.Try, if there is one, and otherwise begins a new transaction.
+	PropagationNested
+
+	// PropagationMandatory joins the transaction in the context, and returns ErrNoTransaction if there is none.
+	PropagationMandatory
+)
+
+// ErrNoTransaction is returned by DoWith with PropagationMandatory when its context carries no transaction, or only
+// one that has ended.
+var ErrNoTransaction = errors.New("sx: no transaction in context")
+
+// ErrRolledBack is returned by Do when the transaction was rolled back because a joined DoWith called Fail(nil).
+var ErrRolledBack = errors.New("sx: transaction rolled back by a joined call")
+
+// WithPropagation sets how DoWith behaves when its context already carries a transaction.  See Propagation.
+//
+// When a transaction is joined, the callback function is run with the existing *Tx, and the other options are
+// ignored.  If the callback function fails, then DoWith returns the error, and the existing transaction is marked so
+// that it will be rolled back, and not committed, when its own callback function returns.  The existing transaction's
+// Do then returns the same error, or ErrRolledBack if the joined callback function called Fail(nil), so that its
+// caller is never told that work which was thrown away succeeded.  When the callback function is run under a
+// savepoint, a failure only undoes the work done since the savepoint.
+//
+// A transaction that has been committed or rolled back is treated as absent, even though its context still carries
+// it.  So a DoWith given the context of an ended transaction, for example from an OnCommit hook or from a goroutine
+// that outlives the callback function, begins a new transaction, or with PropagationMandatory returns
+// ErrNoTransaction.
+func WithPropagation(p Propagation) Option {
+	return func(cfg *config) {
+		cfg.propagation = &p
+	}
+}
+
+type (
+	txKey          struct{}
+	propagationKey struct{}
+)
+
+// ContextWithPropagation returns a context that sets the propagation for DoWith, and so for Do and DoContext, when
+// no WithPropagation option is given.  This lets a caller, such as a test harness, make the code that it calls join
+// its transaction without that code having to ask for it.
+func ContextWithPropagation(ctx context.Context, p Propagation) context.Context {
+	return context.WithValue(ctx, propagationKey{}, p)
+}
+
+// TxFromContext returns the transaction carried by ctx, or nil if there is none.  The context of a transaction, as
+// returned by Tx.Context, carries the transaction itself, as does any context derived from it.  This lets helper
+// functions reuse the transaction of their caller.
+func TxFromContext(ctx context.Context) *Tx {
+	tx, _ := ctx.Value(txKey{}).(*Tx)
+	return tx
+}
+
+// propagate runs f according to the propagation in cfg, if ctx carries a transaction.  It reports whether it has
+// done so, and if not, f should be run in a new transaction.
+func propagate(ctx context.Context, f func(*Tx), cfg *config) (handled bool, err error) {
+	p, _ := ctx.Value(propagationKey{}).(Propagation)
+	if cfg.propagation != nil {
+		p = *cfg.propagation
+	}
+	if p == PropagationRequiresNew {
+		return false, nil
+	}
+	tx := TxFromContext(ctx)
+	if tx == nil || tx.ended.Load() {
+		if p == PropagationMandatory {
+			return true, ErrNoTransaction
+		}
+		return false, nil
+	}
+	if p == PropagationNested {
+		return true, tx.Try(f)
+	}
+	return true, tx.join(f)
+}
+
+// join runs f in the existing transaction.  If f fails, the transaction is marked for rollback, and the error is
+// returned.  Panics other than our own are propagated to the enclosing Do.
+func (tx *Tx) join(f func(*Tx)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ourerr, ok := r.(sxError)
+			if !ok {
+				panic(r)
+			}
+			err = ourerr.err
+			if !tx.rollbackOnly {
+				tx.rollbackOnly, tx.rollbackErr = true, err
+			}
+		}
+	}()
+	tx.runShielded(f)
+	return nil
+}
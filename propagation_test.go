@@ -0,0 +1,234 @@
+package sx_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+func TestPropagation(t *testing.T) {
+
+	// insert is a repository function that runs in its own transaction, or joins the caller's.
+	insert := func(ctx context.Context, db *sql.DB, p sx.Propagation, query string) error {
+		return sx.DoWith(ctx, db, func(tx *sx.Tx) {
+			tx.MustExec(query)
+		}, sx.WithPropagation(p))
+	}
+
+	t.Run("TxFromContext", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		if sx.TxFromContext(context.Background()) != nil {
+			t.Errorf("expected no transaction in the background context")
+		}
+		err := sx.Do(db, func(tx *sx.Tx) {
+			ctx, cancel := context.WithCancel(tx.Context())
+			defer cancel()
+			if sx.TxFromContext(ctx) != tx {
+				t.Errorf("expected the transaction in its context")
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("Required joins the transaction", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT bravo 1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT bravo 2").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT bravo 3").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			if err := insert(tx.Context(), db, sx.PropagationRequired, "INSERT bravo 1"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if err := insert(tx.Context(), db, sx.PropagationRequired, "INSERT bravo 2"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		// Without a transaction in the context, a new one is begun.
+		if err := insert(context.Background(), db, sx.PropagationRequired, "INSERT bravo 3"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("Required with error", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("charlie error")
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT charlie").WillReturnError(err0)
+		mock.ExpectExec("SELECT charlie").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		var inner error
+		err := sx.Do(db, func(tx *sx.Tx) {
+			inner = insert(tx.Context(), db, sx.PropagationRequired, "INSERT charlie")
+			// The caller ignores the error, but the transaction can no longer be committed.
+			tx.MustExec("SELECT charlie")
+		})
+		if !errors.Is(inner, err0) {
+			t.Errorf("expected %v from the joined call, got %v", err0, inner)
+		}
+		if err != inner {
+			t.Errorf("expected %v, got %v", inner, err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("Required with Fail(nil)", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		var inner, hooked error
+		err := sx.Do(db, func(tx *sx.Tx) {
+			tx.OnCommit(func() {
+				t.Errorf("expected no commit")
+			})
+			tx.OnRollback(func(err error) {
+				hooked = err
+			})
+			inner = sx.DoWith(tx.Context(), db, func(tx *sx.Tx) {
+				tx.Fail(nil)
+			}, sx.WithPropagation(sx.PropagationRequired))
+		})
+		if inner != nil {
+			t.Errorf("expected nil from the joined call, got %v", inner)
+		}
+		if err != sx.ErrRolledBack || hooked != sx.ErrRolledBack {
+			t.Errorf("expected %v, got %v and %v", sx.ErrRolledBack, err, hooked)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("Required after the transaction has ended", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT hook").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		var hookErr, mandatoryErr error
+		ctx := sx.ContextWithPropagation(context.Background(), sx.PropagationRequired)
+		err := sx.DoContext(ctx, db, func(tx *sx.Tx) {
+			tx.OnCommit(func() {
+				// The context still carries the committed transaction, which must not be joined.
+				hookErr = sx.DoContext(tx.Context(), db, func(tx *sx.Tx) {
+					tx.MustExec("INSERT hook")
+				})
+				mandatoryErr = sx.DoWith(tx.Context(), db, func(tx *sx.Tx) {
+					t.Errorf("callback should not run")
+				}, sx.WithPropagation(sx.PropagationMandatory))
+			})
+		})
+		if err != nil || hookErr != nil {
+			t.Errorf("unexpected errors: %v, %v", err, hookErr)
+		}
+		if mandatoryErr != sx.ErrNoTransaction {
+			t.Errorf("expected %v, got %v", sx.ErrNoTransaction, mandatoryErr)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("RequiresNew begins a new transaction", func(t *testing.T) {
+		db, mock := newMock(t)
+		mock.MatchExpectationsInOrder(false)
+
+		mock.ExpectBegin()
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT delta").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			var innerTx *sx.Tx
+			err := sx.DoWith(tx.Context(), db, func(tx *sx.Tx) {
+				innerTx = tx
+				tx.MustExec("INSERT delta")
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if innerTx == tx {
+				t.Errorf("expected a new transaction")
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("Nested uses a savepoint", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("echo error")
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT echo").WillReturnError(err0)
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			if err := insert(tx.Context(), db, sx.PropagationNested, "INSERT echo"); !errors.Is(err, err0) {
+				t.Errorf("expected %v, got %v", err0, err)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("Mandatory without a transaction", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT foxtrot").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := insert(context.Background(), db, sx.PropagationMandatory, "INSERT foxtrot"); err != sx.ErrNoTransaction {
+			t.Errorf("expected %v, got %v", sx.ErrNoTransaction, err)
+		}
+		err := sx.Do(db, func(tx *sx.Tx) {
+			if err := insert(tx.Context(), db, sx.PropagationMandatory, "INSERT foxtrot"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+}
@@ -0,0 +1,116 @@
+package sx
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// ErrTooManyRows is the error with which QueryOne aborts the transaction when the query returns more than one row.
+var ErrTooManyRows = errors.New("sx: query returned more than one row")
+
+// A MustQueryer is anything that can run a query with MustQuery.  Both *Tx and *Conn are MustQueryers.
+type MustQueryer interface {
+	MustQuery(query string, args ...interface{}) *Rows
+}
+
+// QueryAll runs a query with q.MustQuery and returns all of the rows as a slice of T.  If T is a struct, then each
+// row is scanned into it with MustScans, using the same matching as Columns.  Otherwise, including for structs such
+// as time.Time and those that implement sql.Scanner or driver.Valuer, the query must return a single column, which is
+// scanned into T.  In case of error, the transaction is aborted and Do returns the error code.
+func QueryAll[T any](q MustQueryer, query string, args ...interface{}) []T {
+	return scanAll[T](q.MustQuery(query, args...))
+}
+
+// QueryOne runs a query with q.MustQuery and returns the single row that it is expected to return, scanned into a T
+// as with QueryAll.  If the query returns no rows, then the transaction is aborted with an error wrapping
+// sql.ErrNoRows, and if it returns more than one, with an error wrapping ErrTooManyRows.
+func QueryOne[T any](q MustQueryer, query string, args ...interface{}) T {
+	return scanOne[T](q.MustQuery(query, args...))
+}
+
+// QueryOptional runs a query with q.MustQuery and returns the row that it returns, if any, scanned into a T as with
+// QueryAll.  The boolean result reports whether there was a row.  If the query returns more than one row, then the
+// transaction is aborted with an error wrapping ErrTooManyRows.
+func QueryOptional[T any](q MustQueryer, query string, args ...interface{}) (T, bool) {
+	return scanOptional[T](q.MustQuery(query, args...))
+}
+
+// StmtQueryAll is like QueryAll, for a prepared statement.
+func StmtQueryAll[T any](stmt *Stmt, args ...interface{}) []T {
+	return scanAll[T](stmt.MustQuery(args...))
+}
+
+// StmtQueryOne is like QueryOne, for a prepared statement.
+func StmtQueryOne[T any](stmt *Stmt, args ...interface{}) T {
+	return scanOne[T](stmt.MustQuery(args...))
+}
+
+// StmtQueryOptional is like QueryOptional, for a prepared statement.
+func StmtQueryOptional[T any](stmt *Stmt, args ...interface{}) (T, bool) {
+	return scanOptional[T](stmt.MustQuery(args...))
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// scansAsStruct reports whether T is scanned as a struct, rather than as a single column.
+func scansAsStruct[T any]() bool {
+	t := reflect.TypeFor[T]()
+	return t.Kind() == reflect.Struct && !scansAsWhole(t)
+}
+
+// scanDest returns the destinations into which to scan a row for x, according to whether T is scanned as a struct or
+// as a single column.
+func scanDest[T any](x *T) []interface{} {
+	if scansAsStruct[T]() {
+		return Addrs(x)
+	}
+	return []interface{}{x}
+}
+
+// mustScanRow scans the current row into x, as with MustScans if T is scanned as a struct, and MustScan otherwise.
+func mustScanRow[T any](rows *Rows, x *T) {
+	if scansAsStruct[T]() {
+		rows.MustScans(x)
+	} else {
+		rows.MustScan(x)
+	}
+}
+
+// scanAll reads all of the rows into a slice of T.
+func scanAll[T any](rows *Rows) []T {
+	var all []T
+	for x := range Seq[T](rows) {
+		all = append(all, x)
+	}
+	return all
+}
+
+// scanOptional reads at most one row into a T.
+func scanOptional[T any](rows *Rows) (T, bool) {
+	var x T
+	n := 0
+	rows.Each(func(rows *Rows) {
+		if n++; n > 1 {
+			rows.failed(ErrTooManyRows)
+			rows.cfg.fail(queryFailed(opQuery, rows.query, rows.args, ErrTooManyRows))
+		}
+		mustScanRow(rows, &x)
+	})
+	return x, n == 1
+}
+
+// scanOne reads exactly one row into a T.
+func scanOne[T any](rows *Rows) T {
+	x, ok := scanOptional[T](rows)
+	if !ok {
+		rows.cfg.fail(queryFailed(opQuery, rows.query, rows.args, sql.ErrNoRows))
+	}
+	return x
+}
@@ -0,0 +1,201 @@
+package sx_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+// point is a struct that is stored as a single column, since it implements driver.Valuer.
+type point struct {
+	X, Y int
+}
+
+func (p point) Value() (driver.Value, error) {
+	return nil, nil
+}
+
+func TestQueryAll(t *testing.T) {
+
+	type abc struct {
+		Alpha int
+		Bravo string
+	}
+
+	t.Run("QueryAll with structs", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT alpha,bravo FROM abc"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"alpha", "bravo"}).AddRow(1, "a").AddRow(2, "b"))
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"alpha", "bravo"}))
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			all := sx.QueryAll[abc](tx, query)
+			if len(all) != 2 || all[0] != (abc{1, "a"}) || all[1] != (abc{2, "b"}) {
+				t.Errorf("unexpected rows %v", all)
+			}
+			if none := sx.QueryAll[abc](tx, query); len(none) != 0 {
+				t.Errorf("expected no rows, got %v", none)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("QueryAll with scalars", func(t *testing.T) {
+		db, mock := newMock(t)
+		now := time.Now()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT alpha FROM abc").WillReturnRows(sqlmock.NewRows([]string{"alpha"}).AddRow(1).AddRow(2))
+		mock.ExpectQuery("SELECT now()").WillReturnRows(sqlmock.NewRows([]string{"now"}).AddRow(now))
+		mock.ExpectQuery("SELECT bravo FROM abc").WillReturnRows(sqlmock.NewRows([]string{"bravo"}).AddRow(nil))
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			if ints := sx.QueryAll[int](tx, "SELECT alpha FROM abc"); len(ints) != 2 || ints[0] != 1 || ints[1] != 2 {
+				t.Errorf("unexpected rows %v", ints)
+			}
+			if got := sx.QueryOne[time.Time](tx, "SELECT now()"); !got.Equal(now) {
+				t.Errorf("expected %v, got %v", now, got)
+			}
+			if got := sx.QueryOne[sql.NullString](tx, "SELECT bravo FROM abc"); got.Valid {
+				t.Errorf("expected NULL, got %v", got)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("QueryOne and QueryOptional", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT alpha FROM abc WHERE bravo = ?"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).WithArgs("a").WillReturnRows(sqlmock.NewRows([]string{"alpha"}).AddRow(1))
+		mock.ExpectQuery(query).WithArgs("b").WillReturnRows(sqlmock.NewRows([]string{"alpha"}))
+		mock.ExpectQuery(query).WithArgs("c").WillReturnRows(sqlmock.NewRows([]string{"alpha"}).AddRow(3))
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			if got := sx.QueryOne[int](tx, query, "a"); got != 1 {
+				t.Errorf("expected 1, got %d", got)
+			}
+			if got, ok := sx.QueryOptional[int](tx, query, "b"); ok || got != 0 {
+				t.Errorf("expected no row, got %d, %t", got, ok)
+			}
+			if got, ok := sx.QueryOptional[int](tx, query, "c"); !ok || got != 3 {
+				t.Errorf("expected 3, got %d, %t", got, ok)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("QueryOne with no rows", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT alpha FROM abc"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"alpha"}))
+		mock.ExpectRollback()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			sx.QueryOne[int](tx, query)
+		})
+		var qerr *sx.QueryError
+		if !errors.Is(err, sql.ErrNoRows) || !errors.As(err, &qerr) || qerr.Query != query {
+			t.Errorf("expected a query error wrapping %v, got %v", sql.ErrNoRows, err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("QueryOne with too many rows", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT alpha FROM abc"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"alpha"}).AddRow(1).AddRow(2))
+		mock.ExpectRollback()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			sx.QueryOptional[int](tx, query)
+		})
+		if !errors.Is(err, sx.ErrTooManyRows) {
+			t.Errorf("expected %v, got %v", sx.ErrTooManyRows, err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("statement and connection variants", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT alpha,bravo FROM abc WHERE alpha = ?"
+
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"alpha", "bravo"}).AddRow(1, "a"))
+		prep.ExpectQuery().WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"alpha", "bravo"}))
+		prep.ExpectQuery().WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"alpha", "bravo"}).AddRow(3, "c"))
+		mock.ExpectQuery("SELECT bravo FROM abc").WillReturnRows(sqlmock.NewRows([]string{"bravo"}).AddRow("z"))
+
+		err := sx.Run(db, func(conn *sx.Conn) {
+			conn.MustPrepare(query).Do(func(stmt *sx.Stmt) {
+				if got := sx.StmtQueryOne[abc](stmt, 1); got != (abc{1, "a"}) {
+					t.Errorf("unexpected row %v", got)
+				}
+				if _, ok := sx.StmtQueryOptional[abc](stmt, 2); ok {
+					t.Errorf("expected no row")
+				}
+				if got := sx.StmtQueryAll[abc](stmt, 3); len(got) != 1 || got[0] != (abc{3, "c"}) {
+					t.Errorf("unexpected rows %v", got)
+				}
+			})
+			if got := sx.QueryAll[string](conn, "SELECT bravo FROM abc"); len(got) != 1 || got[0] != "z" {
+				t.Errorf("unexpected rows %v", got)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("struct implementing driver.Valuer is a single column", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT x,y FROM abc"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"x", "y"}).AddRow(1, 2))
+		mock.ExpectRollback()
+
+		// The struct matching treats point as a single column, so QueryAll must not scan it field by field.
+		err := sx.Do(db, func(tx *sx.Tx) {
+			sx.QueryAll[point](tx, query)
+		})
+		var qerr *sx.QueryError
+		if !errors.As(err, &qerr) || qerr.Op != "scan" {
+			t.Errorf("expected a scan error, got %v", err)
+		}
+
+		endMock(t, mock)
+	})
+}
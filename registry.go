@@ -0,0 +1,115 @@
+package sx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// MustStmt returns a transaction-specific version of a statement prepared outside of the transaction, typically on
+// an *sql.DB.  The returned statement is closed automatically when the transaction ends, but the original statement
+// remains open.  The transaction's context is used, both to bind the statement and by the statement's methods that
+// don't take a context.
+//
+// Since an *sql.Stmt doesn't record its SQL text, the errors returned for the statement don't include the query.  Use
+// MustBind to keep the query text.
+func (tx *Tx) MustStmt(stmt *sql.Stmt) *Stmt {
+	return tx.MustStmtContext(tx.Context(), stmt)
+}
+
+// MustStmtContext returns a transaction-specific version of a statement prepared outside of the transaction,
+// typically on an *sql.DB.  The returned statement is closed automatically when the transaction ends.  The
+// statement's methods that don't take a context use the transaction's context, not ctx.
+func (tx *Tx) MustStmtContext(ctx context.Context, stmt *sql.Stmt) *Stmt {
+	return tx.bind(ctx, stmt, "")
+}
+
+// MustBind returns a transaction-specific version of a statement prepared outside of the transaction, for example
+// with a StmtRegistry.  The returned statement is closed automatically when the transaction ends, but the original
+// statement remains open.  The transaction's context is used, both to bind the statement and by the statement's
+// methods that don't take a context.
+func (tx *Tx) MustBind(stmt *Stmt) *Stmt {
+	return tx.MustBindContext(tx.Context(), stmt)
+}
+
+// MustBindContext returns a transaction-specific version of a statement prepared outside of the transaction.  The
+// returned statement is closed automatically when the transaction ends.  The statement's methods that don't take a
+// context use the transaction's context, not ctx.
+func (tx *Tx) MustBindContext(ctx context.Context, stmt *Stmt) *Stmt {
+	return tx.bind(ctx, stmt.Stmt, stmt.query)
+}
+
+// MustNamed returns a transaction-specific version of the statement registered in r under the given name.  The
+// returned statement is closed automatically when the transaction ends.  If there is no such statement, the
+// transaction is aborted and Do returns an error.  The transaction's context is used.
+func (tx *Tx) MustNamed(r *StmtRegistry, name string) *Stmt {
+	stmt := r.Stmt(name)
+	if stmt == nil {
+		tx.cfg.fail(sxError{fmt.Errorf("sx: no statement named %q", name)})
+	}
+	return tx.MustBind(stmt)
+}
+
+// bind binds stmt to the transaction.  database/sql defers any error until the statement is used.
+func (tx *Tx) bind(ctx context.Context, stmt *sql.Stmt, query string) *Stmt {
+	bound := &Stmt{Stmt: tx.StmtContext(ctx, stmt), ctx: tx.ctx, cfg: tx.cfg, query: query}
+	tx.bound = append(tx.bound, bound)
+	return bound
+}
+
+// A StmtRegistry holds statements prepared once on a connection pool, typically at startup, under names of the
+// application's choosing.  Inside a transaction, MustNamed binds a registered statement to the transaction.  The
+// statements may also be used directly, outside of any transaction.
+//
+// A StmtRegistry is safe for concurrent use.  The zero value is not usable; use NewStmtRegistry.
+type StmtRegistry struct {
+	db    Queryer
+	mu    sync.RWMutex
+	stmts map[string]*Stmt
+}
+
+// NewStmtRegistry returns an empty registry of statements to be prepared on db, which is typically an *sql.DB.
+func NewStmtRegistry(db Queryer) *StmtRegistry {
+	return &StmtRegistry{db: db, stmts: make(map[string]*Stmt)}
+}
+
+// Prepare prepares query and registers it under name, replacing and closing any statement already registered under
+// that name.
+func (r *StmtRegistry) Prepare(ctx context.Context, name, query string) error {
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return &QueryError{Op: opPrepare, Query: query, Err: err}
+	}
+	r.mu.Lock()
+	old := r.stmts[name]
+	r.stmts[name] = &Stmt{Stmt: stmt, query: query}
+	r.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// Stmt returns the statement registered under name, or nil if there is none.
+func (r *StmtRegistry) Stmt(name string) *Stmt {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.stmts[name]
+}
+
+// Close closes all of the registered statements and empties the registry.  It returns the first error encountered.
+func (r *StmtRegistry) Close() error {
+	r.mu.Lock()
+	stmts := r.stmts
+	r.stmts = make(map[string]*Stmt)
+	r.mu.Unlock()
+
+	var first error
+	for _, stmt := range stmts {
+		if err := stmt.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
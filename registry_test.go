@@ -0,0 +1,109 @@
+package sx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+func TestMustStmt(t *testing.T) {
+
+	t.Run("MustStmt with a *sql.Stmt", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "UPDATE alpha SET a = ?"
+
+		prep := mock.ExpectPrepare(query)
+		mock.ExpectBegin()
+		prep.ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		stmt, err := db.Prepare(query)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = sx.Do(db, func(tx *sx.Tx) {
+			tx.MustStmt(stmt).MustExec(1)
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("MustNamed with a registry", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT b FROM bravo WHERE id = ?"
+		err0 := errors.New("bravo error")
+
+		prep := mock.ExpectPrepare(query).WillBeClosed()
+		mock.ExpectBegin()
+		prep.ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"b"}).AddRow(2))
+		prep.ExpectQuery().WithArgs(3).WillReturnError(err0)
+		mock.ExpectRollback()
+
+		r := sx.NewStmtRegistry(db)
+		if err := r.Prepare(context.Background(), "bravo", query); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if r.Stmt("charlie") != nil {
+			t.Errorf("expected no statement named charlie")
+		}
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			var b int
+			tx.MustNamed(r, "bravo").MustQueryRow(1).MustScan(&b)
+			if b != 2 {
+				t.Errorf("expected 2, got %d", b)
+			}
+			tx.MustNamed(r, "bravo").MustQueryRow(3).MustScan(&b)
+		})
+		var qerr *sx.QueryError
+		if !errors.As(err, &qerr) || qerr.Query != query || !errors.Is(err, err0) {
+			t.Errorf("expected a query error for %q wrapping %v, got %v", query, err0, err)
+		}
+
+		if err := r.Close(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("MustNamed with an unknown name", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		r := sx.NewStmtRegistry(db)
+		err := sx.Do(db, func(tx *sx.Tx) {
+			tx.MustNamed(r, "charlie")
+		})
+		if err == nil || err.Error() != `sx: no statement named "charlie"` {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("Prepare with error", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT delta"
+		err0 := errors.New("delta error")
+
+		mock.ExpectPrepare(query).WillReturnError(err0)
+
+		r := sx.NewStmtRegistry(db)
+		err := r.Prepare(context.Background(), "delta", query)
+		if !errors.Is(err, err0) {
+			t.Errorf("expected %v, got %v", err0, err)
+		}
+
+		endMock(t, mock)
+	})
+}
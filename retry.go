@@ -0,0 +1,99 @@
+package sx
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Defaults used for the zero fields of a RetryPolicy.
+const (
+	DefaultMaxAttempts = 3
+	DefaultBaseDelay   = 10 * time.Millisecond
+	DefaultMaxDelay    = time.Second
+)
+
+// A RetryPolicy describes when and how often a failed transaction is re-run.  It is used with the WithRetry option.
+//
+// The callback function is run in a fresh transaction on every attempt, so any state that it modifies outside of the
+// database should be reset at the start of the callback.
+//
+// Between attempts, the policy sleeps for a random duration between zero and the current delay.  The delay starts at
+// BaseDelay and doubles after every attempt, up to MaxDelay.  If the context is cancelled, or if its deadline would
+// expire before the next attempt could start, then no further attempts are made.
+type RetryPolicy struct {
+	MaxAttempts int              // maximum number of attempts, including the first (default DefaultMaxAttempts)
+	BaseDelay   time.Duration    // delay before the first retry (default DefaultBaseDelay)
+	MaxDelay    time.Duration    // upper bound on the delay (default DefaultMaxDelay)
+	Retryable   func(error) bool // classifies errors as retryable (default IsRetryable)
+}
+
+// The SQLSTATE codes considered retryable by IsRetryable.
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// IsRetryable reports whether err is a serialization failure or a deadlock, as signalled by SQLSTATE 40001 or 40P01.
+// It recognizes any error in err's chain with a method
+//
+//	SQLState() string
+//
+// which is provided by the common Postgres drivers.  Applications using other drivers should supply their own
+// classifier in RetryPolicy.Retryable.
+func IsRetryable(err error) bool {
+	var e interface{ SQLState() string }
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.SQLState() {
+	case sqlStateSerializationFailure, sqlStateDeadlockDetected:
+		return true
+	}
+	return false
+}
+
+// run calls attempt until it succeeds, returns a non-retryable error, or the policy is exhausted.  The error from the
+// last attempt is returned.
+func (p *RetryPolicy) run(ctx context.Context, attempt func() error) error {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	delay := p.BaseDelay
+	if delay <= 0 {
+		delay = DefaultBaseDelay
+	}
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxDelay
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || n >= maxAttempts || !retryable(err) {
+			return err
+		}
+
+		// Full jitter: sleep anywhere between zero and the current delay.
+		sleep := time.Duration(rand.Int64N(int64(min(delay, maxDelay)) + 1))
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < sleep {
+			return err
+		}
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if delay < maxDelay {
+			delay *= 2
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package sx_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+// sqlStateError mimics the error types of drivers that report a SQLSTATE code.
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestIsRetryable(t *testing.T) {
+
+	var testCases = []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "plain error",
+			err:  errors.New("plain"),
+		},
+		{
+			name: "serialization failure",
+			err:  sqlStateError("40001"),
+			want: true,
+		},
+		{
+			name: "deadlock",
+			err:  sqlStateError("40P01"),
+			want: true,
+		},
+		{
+			name: "wrapped deadlock",
+			err:  fmt.Errorf("wrapped: %w", sqlStateError("40P01")),
+			want: true,
+		},
+		{
+			name: "unique violation",
+			err:  sqlStateError("23505"),
+		},
+	}
+
+	for _, c := range testCases {
+		if a, b := c.want, sx.IsRetryable(c.err); a != b {
+			t.Errorf("case %s: expected %t, got %t", c.name, a, b)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+
+	policy := sx.RetryPolicy{BaseDelay: time.Microsecond, MaxDelay: time.Millisecond}
+
+	t.Run("retry after serialization failure", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "UPDATE alpha"
+		err0 := sqlStateError("40001")
+
+		mock.ExpectBegin()
+		mock.ExpectExec(query).WillReturnError(err0)
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		n := 0
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			n++
+			tx.MustExec(query)
+		}, sx.WithRetry(policy))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if n != 2 {
+			t.Errorf("expected 2 attempts, got %d", n)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("retry after failed commit", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := sqlStateError("40001")
+
+		mock.ExpectBegin()
+		mock.ExpectCommit().WillReturnError(err0)
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {}, sx.WithRetry(policy))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("give up after max attempts", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := sqlStateError("40P01")
+
+		for i := 0; i < 2; i++ {
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+		}
+
+		p := policy
+		p.MaxAttempts = 2
+		n := 0
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			n++
+			tx.Fail(err0)
+		}, sx.WithRetry(p))
+		if err != err0 {
+			t.Errorf("expected error %v, got %v", err0, err)
+		}
+		if n != 2 {
+			t.Errorf("expected 2 attempts, got %d", n)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("no retry on other errors", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("bravo error")
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.Fail(err0)
+		}, sx.WithRetry(policy))
+		if err != err0 {
+			t.Errorf("expected error %v, got %v", err0, err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("custom classifier", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("charlie error")
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		p := policy
+		p.Retryable = func(err error) bool { return err == err0 }
+		n := 0
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			n++
+			if n == 1 {
+				tx.Fail(err0)
+			}
+		}, sx.WithRetry(p))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("stop when context is done", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := sqlStateError("40001")
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		err := sx.DoWith(ctx, db, func(tx *sx.Tx) {
+			cancel()
+			tx.Fail(err0)
+		}, sx.WithRetry(sx.RetryPolicy{BaseDelay: time.Hour, MaxDelay: time.Hour}))
+		if err != err0 {
+			t.Errorf("expected error %v, got %v", err0, err)
+		}
+
+		endMock(t, mock)
+	})
+}
@@ -0,0 +1,114 @@
+package sx
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownColumn is wrapped by the error with which MustScansByName aborts the transaction when, under
+// WithStrictColumns, a result column matches no field of the struct.
+var ErrUnknownColumn = errors.New("sx: result column matches no struct field")
+
+// A scanPlan maps the columns of a result set onto the fields of a struct, by name.
+type scanPlan struct {
+	reflectType reflect.Type
+	columns     []*column // the field into which to scan each result column, or nil to discard the column
+	unknown     []string  // the result columns which match no field
+}
+
+type scanPlanKey struct {
+	reflectType reflect.Type
+	columns     string // the names of the result columns, separated by NUL characters
+}
+
+// Cache of the scan plans that have been generated, keyed by struct type and result columns.
+var scanPlanCache = make(map[scanPlanKey]*scanPlan)
+var scanPlanCacheMu sync.Mutex
+
+// scanPlanOf returns a scan plan from the given result columns to the struct of matching m, generating it if
+// necessary.
+func scanPlanOf(m *matching, columns []string) *scanPlan {
+	scanPlanCacheMu.Lock()
+	defer scanPlanCacheMu.Unlock()
+
+	key := scanPlanKey{m.reflectType, strings.Join(columns, "\x00")}
+	if p, ok := scanPlanCache[key]; ok {
+		return p
+	}
+
+	byName := make(map[string]*column, len(m.columns))
+	for _, c := range m.columns {
+		byName[c.name] = c
+	}
+	p := &scanPlan{
+		reflectType: m.reflectType,
+		columns:     make([]*column, len(columns)),
+	}
+	for i, name := range columns {
+		if c, ok := byName[name]; ok {
+			p.columns[i] = c
+		} else {
+			p.unknown = append(p.unknown, name)
+		}
+	}
+	scanPlanCache[key] = p
+	return p
+}
+
+// addrs returns the destinations into which to scan a row for the struct pointed at by dest.
+func (p *scanPlan) addrs(dest interface{}) []interface{} {
+	val := reflect.ValueOf(dest).Elem()
+	addrs := make([]interface{}, len(p.columns))
+	for i, c := range p.columns {
+		if c == nil {
+			addrs[i] = discard{}
+		} else {
+			addrs[i] = c.addrOf(val)
+		}
+	}
+	return addrs
+}
+
+// unknownError returns the error reporting the result columns which match no field.
+func (p *scanPlan) unknownError() error {
+	return fmt.Errorf("%w: %s has no field for column %s", ErrUnknownColumn, p.reflectType,
+		strings.Join(p.unknown, ", "))
+}
+
+// discard is a scan destination that throws the value away.
+type discard struct{}
+
+func (discard) Scan(interface{}) error { return nil }
+
+// MustScansByName copies the columns in the current row into the struct pointed at by dest, matching each result
+// column to the field with the same column name, as given by Columns.  Unlike MustScans, the columns may come in any
+// order, so MustScansByName is suitable for queries such as SELECT *.  Fields with no matching column are left
+// unchanged.  Result columns which match no field are discarded, unless the transaction was run with
+// WithStrictColumns, in which case the transaction is aborted with an error wrapping ErrUnknownColumn.  In case of
+// error, the transaction is aborted and Do returns the error code.
+//
+// The mapping is worked out once for each combination of struct type and result columns, and cached.
+//
+// There is no such method for Row, since sql.Row does not report its columns.
+func (rows *Rows) MustScansByName(dest interface{}) {
+	m := matchingOf(dest)
+	p := rows.plan
+	if p == nil || p.reflectType != m.reflectType {
+		columns, err := rows.Columns()
+		if err != nil {
+			rows.failed(err)
+			rows.cfg.fail(queryFailed(opScan, rows.query, rows.args, err))
+		}
+		p = scanPlanOf(m, columns)
+		rows.plan = p
+	}
+	if len(p.unknown) > 0 && rows.cfg.strictColumnsSet() {
+		err := p.unknownError()
+		rows.failed(err)
+		rows.cfg.fail(queryFailed(opScan, rows.query, rows.args, err))
+	}
+	rows.MustScan(p.addrs(dest)...)
+}
@@ -0,0 +1,94 @@
+package sx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+func TestMustScansByName(t *testing.T) {
+
+	type abc struct {
+		Alpha   int
+		Bravo   string `sx:"b"`
+		Charlie bool
+	}
+
+	t.Run("reordered columns", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT * FROM abc"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"charlie", "b", "alpha"}).
+			AddRow(true, "one", 1).AddRow(false, "two", 2))
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			var got []abc
+			tx.MustQuery(query).Each(func(rows *sx.Rows) {
+				var x abc
+				rows.MustScansByName(&x)
+				got = append(got, x)
+			})
+			if len(got) != 2 || got[0] != (abc{1, "one", true}) || got[1] != (abc{2, "two", false}) {
+				t.Errorf("unexpected rows %v", got)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("unknown and missing columns", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT * FROM abc"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"delta", "alpha"}).AddRow("x", 1))
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			x := abc{Bravo: "kept"}
+			tx.MustQuery(query).Each(func(rows *sx.Rows) {
+				rows.MustScansByName(&x)
+			})
+			if x != (abc{Alpha: 1, Bravo: "kept"}) {
+				t.Errorf("unexpected row %v", x)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("unknown column with WithStrictColumns", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT * FROM abc"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"delta", "alpha"}).AddRow("x", 1))
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.MustQuery(query).Each(func(rows *sx.Rows) {
+				var x abc
+				rows.MustScansByName(&x)
+			})
+			t.Errorf("expected the transaction to be aborted")
+		}, sx.WithStrictColumns())
+		var qerr *sx.QueryError
+		if !errors.Is(err, sx.ErrUnknownColumn) || !errors.As(err, &qerr) || qerr.Op != "scan" {
+			t.Errorf("expected a scan error wrapping %v, got %v", sx.ErrUnknownColumn, err)
+		}
+
+		endMock(t, mock)
+	})
+}
@@ -0,0 +1,147 @@
+package sx
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Outcomes of a transaction, as recorded by SlowLog.
+const (
+	OutcomeCommit   = "commit"   // the transaction was committed
+	OutcomeRollback = "rollback" // the transaction was rolled back by calling Fail(nil), possibly in a joined DoWith
+	OutcomeError    = "error"    // the transaction failed, or could not be begun or committed
+)
+
+// A SlowLog is a Tracer that logs slow statements and transactions with log/slog.  Register it globally with
+// SetTracer, or for a single transaction with the WithTracer option:
+//
+//	sx.SetTracer(&sx.SlowLog{
+//		QueryThreshold:       100 * time.Millisecond,
+//		TransactionThreshold: time.Second,
+//		Redactor:             sx.RedactAll,
+//	})
+//
+// A statement record has the message "sx: statement" and the attributes "op", "sql", "args", "elapsed",
+// "rows_affected" (for exec only), "rows_read" and, if the statement failed, "error".
+//
+// A transaction record has the message "sx: transaction" and the attributes "outcome" (see OutcomeCommit and
+// friends), "elapsed", "statements", "rows_affected", "rows_read", "slowest_sql", "slowest_elapsed" and, if the
+// transaction failed, "error".  The counts cover the statements run with the transaction's context.
+//
+// A zero threshold logs every statement or transaction, and a negative threshold logs none.
+type SlowLog struct {
+	Logger               *slog.Logger  // destination of the records (default slog.Default())
+	Level                slog.Level    // level of the records (default slog.LevelInfo)
+	QueryThreshold       time.Duration // minimum duration of a statement to be logged
+	TransactionThreshold time.Duration // minimum duration of a transaction to be logged
+	Redactor             Redactor      // applied to the arguments (default the Redactor set with SetRedactor)
+}
+
+// slowLogKey is the context key under which a SlowLog keeps track of the statements in a transaction.
+type slowLogKey struct {
+	log *SlowLog
+}
+
+// slowLogTx accumulates the statements run within a transaction.
+type slowLogTx struct {
+	statements     int
+	rowsAffected   int64
+	rowsRead       int64
+	slowestSQL     string
+	slowestElapsed time.Duration
+}
+
+func (l *SlowLog) logger() *slog.Logger {
+	if l.Logger == nil {
+		return slog.Default()
+	}
+	return l.Logger
+}
+
+func (l *SlowLog) redact(args []interface{}) []interface{} {
+	if l.Redactor == nil {
+		return redact(args)
+	}
+	return l.Redactor(args)
+}
+
+// BeforeBegin starts counting the statements in a transaction.
+func (l *SlowLog) BeforeBegin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, slowLogKey{l}, &slowLogTx{})
+}
+
+// AfterCommit logs a committed transaction, if it was slow.
+func (l *SlowLog) AfterCommit(ctx context.Context, elapsed time.Duration) {
+	l.logTransaction(ctx, elapsed, OutcomeCommit, nil)
+}
+
+// AfterRollback logs a failed transaction, if it was slow.
+func (l *SlowLog) AfterRollback(ctx context.Context, elapsed time.Duration, err error) {
+	outcome := OutcomeRollback
+	if err != nil && err != ErrRolledBack {
+		outcome = OutcomeError
+	}
+	l.logTransaction(ctx, elapsed, outcome, err)
+}
+
+// BeforeQuery does nothing.
+func (l *SlowLog) BeforeQuery(ctx context.Context, q *QueryInfo) context.Context {
+	return ctx
+}
+
+// AfterQuery logs a statement, if it was slow, and adds it to the counts for the enclosing transaction.
+func (l *SlowLog) AfterQuery(ctx context.Context, q *QueryInfo) {
+	if st, ok := ctx.Value(slowLogKey{l}).(*slowLogTx); ok {
+		st.statements++
+		if q.RowsAffected > 0 {
+			st.rowsAffected += q.RowsAffected
+		}
+		st.rowsRead += q.RowsRead
+		if q.Elapsed > st.slowestElapsed || st.slowestSQL == "" {
+			st.slowestSQL = q.Query
+			st.slowestElapsed = q.Elapsed
+		}
+	}
+
+	if l.QueryThreshold < 0 || q.Elapsed < l.QueryThreshold {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("op", q.Op),
+		slog.String("sql", q.Query),
+		slog.Any("args", l.redact(q.Args)),
+		slog.Duration("elapsed", q.Elapsed),
+	}
+	if q.Op == opExec {
+		attrs = append(attrs, slog.Int64("rows_affected", q.RowsAffected))
+	}
+	attrs = append(attrs, slog.Int64("rows_read", q.RowsRead))
+	if q.Err != nil {
+		attrs = append(attrs, slog.Any("error", q.Err))
+	}
+	l.logger().LogAttrs(ctx, l.Level, "sx: statement", attrs...)
+}
+
+func (l *SlowLog) logTransaction(ctx context.Context, elapsed time.Duration, outcome string, err error) {
+	if l.TransactionThreshold < 0 || elapsed < l.TransactionThreshold {
+		return
+	}
+	st, _ := ctx.Value(slowLogKey{l}).(*slowLogTx)
+	if st == nil {
+		st = &slowLogTx{}
+	}
+	attrs := []slog.Attr{
+		slog.String("outcome", outcome),
+		slog.Duration("elapsed", elapsed),
+		slog.Int("statements", st.statements),
+		slog.Int64("rows_affected", st.rowsAffected),
+		slog.Int64("rows_read", st.rowsRead),
+		slog.String("slowest_sql", st.slowestSQL),
+		slog.Duration("slowest_elapsed", st.slowestElapsed),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	l.logger().LogAttrs(ctx, l.Level, "sx: transaction", attrs...)
+}
@@ -0,0 +1,157 @@
+package sx_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+// newSlowLogger returns a logger that writes JSON records to buf, without timestamps.
+func newSlowLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// slowLogRecords decodes the records written by a logger from newSlowLogger.
+func slowLogRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var r map[string]interface{}
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("error decoding log record: %v", err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestSlowLog(t *testing.T) {
+
+	t.Run("zero thresholds log everything", func(t *testing.T) {
+		db, mock := newMock(t)
+		var buf bytes.Buffer
+		l := &sx.SlowLog{Logger: newSlowLogger(&buf), Redactor: sx.RedactAll}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE alpha SET a = ?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectQuery("SELECT a FROM alpha").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(1).AddRow(2))
+		mock.ExpectCommit()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.MustExec("UPDATE alpha SET a = ?", 1)
+			tx.MustQuery("SELECT a FROM alpha").Each(func(rows *sx.Rows) {})
+		}, sx.WithTracer(l))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		records := slowLogRecords(t, &buf)
+		if len(records) != 3 {
+			t.Fatalf("expected 3 records, got %d", len(records))
+		}
+		exec, query, txn := records[0], records[1], records[2]
+		if exec["msg"] != "sx: statement" || exec["op"] != "exec" || exec["sql"] != "UPDATE alpha SET a = ?" ||
+			exec["rows_affected"] != 3.0 || exec["rows_read"] != 0.0 {
+			t.Errorf("unexpected exec record %v", exec)
+		}
+		if args, _ := exec["args"].([]interface{}); len(args) != 1 || args[0] != "[redacted]" {
+			t.Errorf("expected redacted arguments, got %v", exec["args"])
+		}
+		if _, ok := query["rows_affected"]; ok || query["op"] != "query" || query["rows_read"] != 2.0 {
+			t.Errorf("unexpected query record %v", query)
+		}
+		if txn["msg"] != "sx: transaction" || txn["outcome"] != sx.OutcomeCommit || txn["statements"] != 2.0 ||
+			txn["rows_affected"] != 3.0 || txn["rows_read"] != 2.0 || txn["slowest_sql"] == "" {
+			t.Errorf("unexpected transaction record %v", txn)
+		}
+		if _, ok := txn["error"]; ok {
+			t.Errorf("unexpected error in transaction record %v", txn)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("only slow statements are logged", func(t *testing.T) {
+		db, mock := newMock(t)
+		var buf bytes.Buffer
+		l := &sx.SlowLog{
+			Logger:               newSlowLogger(&buf),
+			Level:                slog.LevelWarn,
+			QueryThreshold:       20 * time.Millisecond,
+			TransactionThreshold: -1,
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT fast").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SELECT slow").WillDelayFor(30 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.MustExec("SELECT fast")
+			tx.MustExec("SELECT slow")
+		}, sx.WithTracer(l))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		records := slowLogRecords(t, &buf)
+		if len(records) != 1 {
+			t.Fatalf("expected 1 record, got %d", len(records))
+		}
+		if records[0]["sql"] != "SELECT slow" || records[0]["level"] != "WARN" {
+			t.Errorf("unexpected record %v", records[0])
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("rollback and error outcomes", func(t *testing.T) {
+		db, mock := newMock(t)
+		var buf bytes.Buffer
+		l := &sx.SlowLog{Logger: newSlowLogger(&buf), QueryThreshold: -1}
+		err0 := errors.New("charlie error")
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT charlie").WillReturnError(err0)
+		mock.ExpectRollback()
+
+		sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.Fail(nil)
+		}, sx.WithTracer(l))
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.MustExec("SELECT charlie")
+		}, sx.WithTracer(l))
+
+		records := slowLogRecords(t, &buf)
+		if len(records) != 2 {
+			t.Fatalf("expected 2 records, got %d", len(records))
+		}
+		if records[0]["outcome"] != sx.OutcomeRollback {
+			t.Errorf("unexpected record %v", records[0])
+		}
+		if records[1]["outcome"] != sx.OutcomeError || records[1]["error"] != err.Error() ||
+			records[1]["slowest_sql"] != "SELECT charlie" {
+			t.Errorf("unexpected record %v", records[1])
+		}
+
+		endMock(t, mock)
+	})
+}
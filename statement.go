@@ -0,0 +1,72 @@
+package sx
+
+import (
+	"context"
+	"database/sql"
+)
+
+// The functions in this file run a single statement on behalf of the Must*** methods of Tx, Conn and Stmt.  The
+// statement itself is run by the function run, with the context that it is given.  cfg holds the options of the
+// enclosing transaction, and is nil outside of a transaction.
+
+// mustExec runs a statement that returns no rows, panicking on error.
+func mustExec(ctx context.Context, cfg *config, query string, args []interface{},
+	run func(context.Context) (sql.Result, error)) sql.Result {
+
+	ctx, cancel := cfg.statementContext(ctx)
+	defer cancel()
+	ctx, qt := cfg.beforeQuery(ctx, opExec, query, args)
+	res, err := run(ctx)
+	if err != nil {
+		err = timeoutError(ctx, err)
+	}
+	qt.done(res, 0, err)
+	if err != nil {
+		cfg.fail(queryFailed(opExec, query, args, err))
+	}
+	return res
+}
+
+// mustQuery runs a statement that returns rows, panicking on error.
+func mustQuery(ctx context.Context, cfg *config, query string, args []interface{},
+	run func(context.Context) (*sql.Rows, error)) *Rows {
+
+	ctx, cancel := cfg.statementContext(ctx)
+	ctx, qt := cfg.beforeQuery(ctx, opQuery, query, args)
+	rows, err := run(ctx)
+	if err != nil {
+		err = timeoutError(ctx, err)
+		qt.done(nil, 0, err)
+		cancel()
+		cfg.fail(queryFailed(opQuery, query, args, err))
+	}
+	return &Rows{Rows: rows, ctx: ctx, cancel: cancel, cfg: cfg, trace: qt, query: query, args: args}
+}
+
+// mustQueryRow runs a statement that returns at most one row.  Errors are deferred until the row is scanned.
+func mustQueryRow(ctx context.Context, cfg *config, query string, args []interface{},
+	run func(context.Context) *sql.Row) *Row {
+
+	ctx, cancel := cfg.statementContext(ctx)
+	ctx, qt := cfg.beforeQuery(ctx, opQuery, query, args)
+	return &Row{Row: run(ctx), ctx: ctx, cancel: cancel, cfg: cfg, trace: qt, query: query, args: args}
+}
+
+// mustPrepare prepares a statement, panicking on error.  The statement's methods that don't take a context will use
+// stmtCtx.
+func mustPrepare(ctx context.Context, cfg *config, query string, stmtCtx context.Context,
+	run func(context.Context) (*sql.Stmt, error)) *Stmt {
+
+	ctx, cancel := cfg.statementContext(ctx)
+	defer cancel()
+	ctx, qt := cfg.beforeQuery(ctx, opPrepare, query, nil)
+	stmt, err := run(ctx)
+	if err != nil {
+		err = timeoutError(ctx, err)
+	}
+	qt.done(nil, 0, err)
+	if err != nil {
+		cfg.fail(queryFailed(opPrepare, query, nil, err))
+	}
+	return &Stmt{Stmt: stmt, ctx: stmtCtx, cfg: cfg, query: query}
+}
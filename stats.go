@@ -0,0 +1,91 @@
+package sx
+
+import (
+	"context"
+	"time"
+)
+
+// Stats describes the work done by a transaction.  It is filled in by DoWith when the WithStats option is given.
+// With the WithRetry option, it describes the last attempt.
+//
+// The statements counted are those run by the Must*** methods of the transaction and of the statements prepared
+// within it.  Statements run by Try to manage savepoints are included.
+type Stats struct {
+	Execs        int              // number of statements run with MustExec
+	Queries      int              // number of statements run with MustQuery or MustQueryRow
+	Prepares     int              // number of statements prepared with MustPrepare, or by the statement cache
+	CacheHits    int              // number of statements supplied by the statement cache (see WithStatementCache)
+	CacheMisses  int              // number of statements prepared by the statement cache
+	RowsRead     int64            // number of rows read from queries, whether with Each, MustScan or MustScans
+	RowsAffected int64            // number of rows affected by MustExec, where reported by the driver
+	QueryTime    time.Duration    // total time taken by the statements
+	CommitTime   time.Duration    // time taken to commit the transaction
+	Elapsed      time.Duration    // time taken by the whole transaction, from begin to commit or rollback
+	Statements   []StatementStats // every statement, in the order in which it was run
+}
+
+// StatementStats describes a single statement run within a transaction.
+type StatementStats struct {
+	Op           string        // the operation: "exec", "query" or "prepare"
+	Query        string        // the SQL text of the statement
+	Elapsed      time.Duration // the time taken by the statement, including reading rows
+	RowsRead     int64         // the number of rows read by a query
+	RowsAffected int64         // the number of rows affected by an exec, or -1 if not applicable or unknown
+	Err          error         // the error that caused the statement to fail, if any
+}
+
+// WithStats collects statistics about the transaction into s.  s is reset when the transaction begins.  Since
+// queries are only counted once their rows have been closed, s is complete once DoWith returns.
+func WithStats(s *Stats) Option {
+	return func(cfg *config) {
+		cfg.stats = s
+		cfg.tracers = append(cfg.tracers, statsTracer{s})
+	}
+}
+
+// A statsTracer is the Tracer that fills in a Stats.
+type statsTracer struct {
+	s *Stats
+}
+
+func (st statsTracer) BeforeBegin(ctx context.Context) context.Context {
+	*st.s = Stats{}
+	return ctx
+}
+
+func (st statsTracer) AfterCommit(ctx context.Context, elapsed time.Duration) {
+	st.s.Elapsed = elapsed
+}
+
+func (st statsTracer) AfterRollback(ctx context.Context, elapsed time.Duration, err error) {
+	st.s.Elapsed = elapsed
+}
+
+func (st statsTracer) BeforeQuery(ctx context.Context, q *QueryInfo) context.Context {
+	return ctx
+}
+
+func (st statsTracer) AfterQuery(ctx context.Context, q *QueryInfo) {
+	s := st.s
+	switch q.Op {
+	case opExec:
+		s.Execs++
+	case opQuery:
+		s.Queries++
+	case opPrepare:
+		s.Prepares++
+	}
+	s.RowsRead += q.RowsRead
+	if q.RowsAffected > 0 {
+		s.RowsAffected += q.RowsAffected
+	}
+	s.QueryTime += q.Elapsed
+	s.Statements = append(s.Statements, StatementStats{
+		Op:           q.Op,
+		Query:        q.Query,
+		Elapsed:      q.Elapsed,
+		RowsRead:     q.RowsRead,
+		RowsAffected: q.RowsAffected,
+		Err:          q.Err,
+	})
+}
@@ -0,0 +1,99 @@
+package sx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+func TestStats(t *testing.T) {
+
+	t.Run("counts and timings", func(t *testing.T) {
+		db, mock := newMock(t)
+		var stats sx.Stats
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id FROM alpha").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3))
+		mock.ExpectPrepare("UPDATE alpha SET b = ? WHERE id = ?")
+		for i := 1; i <= 3; i++ {
+			mock.ExpectExec("UPDATE alpha SET b = ? WHERE id = ?").WithArgs(0, i).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectQuery("SELECT count(*) FROM alpha").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectCommit()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			var ids []int
+			tx.MustQuery("SELECT id FROM alpha").Each(func(rows *sx.Rows) {
+				var id int
+				rows.MustScan(&id)
+				ids = append(ids, id)
+			})
+			stmt := tx.MustPrepare("UPDATE alpha SET b = ? WHERE id = ?")
+			for _, id := range ids {
+				stmt.MustExec(0, id)
+			}
+			var n int
+			tx.MustQueryRow("SELECT count(*) FROM alpha").MustScan(&n)
+		}, sx.WithStats(&stats))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if stats.Execs != 3 || stats.Queries != 2 || stats.Prepares != 1 {
+			t.Errorf("expected 3 execs, 2 queries and 1 prepare, got %d, %d and %d",
+				stats.Execs, stats.Queries, stats.Prepares)
+		}
+		if stats.RowsRead != 4 || stats.RowsAffected != 3 {
+			t.Errorf("expected 4 rows read and 3 affected, got %d and %d", stats.RowsRead, stats.RowsAffected)
+		}
+		if len(stats.Statements) != 6 {
+			t.Fatalf("expected 6 statements, got %d", len(stats.Statements))
+		}
+		if s := stats.Statements[0]; s.Op != "query" || s.Query != "SELECT id FROM alpha" || s.RowsRead != 3 {
+			t.Errorf("unexpected first statement %+v", s)
+		}
+		if s := stats.Statements[2]; s.Op != "exec" || s.RowsAffected != 1 {
+			t.Errorf("unexpected third statement %+v", s)
+		}
+		if stats.CommitTime <= 0 {
+			t.Errorf("expected a commit time, got %v", stats.CommitTime)
+		}
+		if stats.Elapsed < stats.CommitTime+stats.QueryTime {
+			t.Errorf("expected elapsed time %v to cover commit %v and queries %v",
+				stats.Elapsed, stats.CommitTime, stats.QueryTime)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("stats are reset, with error", func(t *testing.T) {
+		db, mock := newMock(t)
+		stats := sx.Stats{Execs: 42}
+		err0 := errors.New("bravo error")
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT bravo").WillReturnError(err0)
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.MustExec("SELECT bravo")
+		}, sx.WithStats(&stats))
+		if !errors.Is(err, err0) {
+			t.Errorf("expected %v, got %v", err0, err)
+		}
+
+		if stats.Execs != 1 || len(stats.Statements) != 1 || !errors.Is(stats.Statements[0].Err, err0) {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+		if stats.CommitTime != 0 {
+			t.Errorf("expected no commit time, got %v", stats.CommitTime)
+		}
+
+		endMock(t, mock)
+	})
+}
@@ -0,0 +1,71 @@
+package sx
+
+import "context"
+
+// WithStatementCache makes MustExec, MustQuery and MustQueryRow, and their Context variants, prepare each distinct
+// query on first use and reuse the prepared statement for the rest of the transaction.  The statements are closed
+// when the transaction ends.  Use Tx.StatementCacheStats, or the CacheHits and CacheMisses fields of Stats, to see
+// how effective the cache is.
+//
+// Statements run by Try to manage savepoints are not cached.
+func WithStatementCache() Option {
+	return func(cfg *config) {
+		cfg.stmtCache = true
+	}
+}
+
+// A stmtCache holds the statements prepared on behalf of the Must*** methods of a transaction, keyed by SQL text.
+type stmtCache struct {
+	stmts  map[string]*Stmt
+	hits   int
+	misses int
+}
+
+// cachedStmt returns the cached statement for query, preparing it if necessary, or nil if the statement cache is not
+// enabled.
+func (tx *Tx) cachedStmt(ctx context.Context, query string) *Stmt {
+	c := tx.stmtCache
+	if c == nil {
+		return nil
+	}
+	if stmt, ok := c.stmts[query]; ok {
+		c.hits++
+		if tx.cfg.stats != nil {
+			tx.cfg.stats.CacheHits++
+		}
+		return stmt
+	}
+	c.misses++
+	if tx.cfg.stats != nil {
+		tx.cfg.stats.CacheMisses++
+	}
+	stmt := tx.MustPrepareContext(ctx, query)
+	if c.stmts == nil {
+		c.stmts = make(map[string]*Stmt)
+	}
+	c.stmts[query] = stmt
+	return stmt
+}
+
+// StatementCacheStats returns the number of times that the statement cache has supplied a prepared statement, and
+// the number of times that it had to prepare one.  Both are zero unless the WithStatementCache option was given.
+func (tx *Tx) StatementCacheStats() (hits, misses int) {
+	if tx.stmtCache == nil {
+		return 0, 0
+	}
+	return tx.stmtCache.hits, tx.stmtCache.misses
+}
+
+// closeStatements closes the statements in the cache, if any, and those bound to the transaction.
+func (tx *Tx) closeStatements() {
+	if tx.stmtCache != nil {
+		for _, stmt := range tx.stmtCache.stmts {
+			stmt.Close()
+		}
+		tx.stmtCache.stmts = nil
+	}
+	for _, stmt := range tx.bound {
+		stmt.Close()
+	}
+	tx.bound = nil
+}
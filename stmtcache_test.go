@@ -0,0 +1,118 @@
+package sx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+func TestStatementCache(t *testing.T) {
+
+	t.Run("hits and misses", func(t *testing.T) {
+		db, mock := newMock(t)
+		var stats sx.Stats
+		const (
+			exec  = "UPDATE alpha SET a = ? WHERE id = ?"
+			query = "SELECT a FROM alpha WHERE id = ?"
+		)
+
+		mock.ExpectBegin()
+		prepExec := mock.ExpectPrepare(exec).WillBeClosed()
+		for i := 0; i < 3; i++ {
+			prepExec.ExpectExec().WithArgs(i, i).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		prepQuery := mock.ExpectPrepare(query).WillBeClosed()
+		prepQuery.ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(1))
+		prepQuery.ExpectQuery().WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(2))
+		mock.ExpectExec("SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("RELEASE SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			for i := 0; i < 3; i++ {
+				tx.MustExec(exec, i, i)
+			}
+			var a int
+			tx.MustQueryRow(query, 1).MustScan(&a)
+			tx.MustQuery(query, 2).Each(func(rows *sx.Rows) {})
+			tx.Try(func(tx *sx.Tx) {})
+
+			if hits, misses := tx.StatementCacheStats(); hits != 3 || misses != 2 {
+				t.Errorf("expected 3 hits and 2 misses, got %d and %d", hits, misses)
+			}
+		}, sx.WithStatementCache(), sx.WithStats(&stats))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if stats.CacheHits != 3 || stats.CacheMisses != 2 || stats.Prepares != 2 || stats.Execs != 5 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("statements are closed on rollback", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "DELETE FROM bravo"
+		err0 := errors.New("bravo error")
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare(query).WillBeClosed().ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.MustExec(query)
+			tx.Fail(err0)
+		}, sx.WithStatementCache())
+		if err != err0 {
+			t.Errorf("expected %v, got %v", err0, err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("prepare error", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT charlie"
+		err0 := errors.New("charlie error")
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare(query).WillReturnError(err0)
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.MustExec(query)
+		}, sx.WithStatementCache())
+		var qerr *sx.QueryError
+		if !errors.As(err, &qerr) || qerr.Op != "prepare" || !errors.Is(err, err0) {
+			t.Errorf("expected a prepare error wrapping %v, got %v", err0, err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("cache disabled by default", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT delta").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			tx.MustExec("SELECT delta")
+			if hits, misses := tx.StatementCacheStats(); hits != 0 || misses != 0 {
+				t.Errorf("expected no hits or misses, got %d and %d", hits, misses)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+}
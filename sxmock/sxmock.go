@@ -0,0 +1,126 @@
+// Package sxmock builds go-sqlmock rows and expectations from the same struct matching that package sx uses, so that
+// mocked column lists and queries don't drift from the structs that they describe.
+//
+// The expectations are for the exact SQL generated by sx, so the mock should be created with
+// sqlmock.QueryMatcherEqual, as New does.
+package sxmock
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+// New creates a mock database whose expectations match the SQL text exactly.
+func New() (*sql.DB, sqlmock.Sqlmock, error) {
+	return sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+}
+
+// Rows returns mocked rows holding the structs in data, which is a slice of structs or of pointers to structs.  The
+// columns are those given by sx.Columns, and each row holds the values of all the matched fields, including those
+// tagged "readonly", as they would be scanned by sx.Addrs.
+//
+// Panics if data is not a slice of structs or of pointers to structs.
+func Rows(data interface{}) *sqlmock.Rows {
+	slice := reflect.ValueOf(data)
+	if slice.Kind() != reflect.Slice {
+		panic("sxmock: expected a slice of structs")
+	}
+	elemType := slice.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		panic("sxmock: expected a slice of structs")
+	}
+
+	rows := sqlmock.NewRows(sx.Columns(reflect.New(elemType).Interface()))
+	for i := 0; i < slice.Len(); i++ {
+		elem := slice.Index(i)
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		// Addrs needs a pointer, and allocates nil pointers to embedded structs, so work on a copy.
+		p := reflect.New(elemType)
+		p.Elem().Set(elem)
+		addrs := sx.Addrs(p.Interface())
+		values := make([]driver.Value, len(addrs))
+		for j, addr := range addrs {
+			values[j] = reflect.ValueOf(addr).Elem().Interface()
+		}
+		rows.AddRow(values...)
+	}
+	return rows
+}
+
+// ExpectSelect expects the query generated by sx.SelectQuery for the struct type of data, followed by the given
+// suffix, such as a WHERE clause, and makes it return the structs in data as with Rows.  Arguments for the suffix
+// may be set on the returned expectation with WithArgs.
+func ExpectSelect(mock sqlmock.Sqlmock, table string, data interface{}, suffix string) *sqlmock.ExpectedQuery {
+	rows := Rows(data)
+	elemType := reflect.TypeOf(data).Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	query := sx.SelectQuery(table, reflect.New(elemType).Interface()) + suffix
+	return mock.ExpectQuery(query).WillReturnRows(rows)
+}
+
+// ExpectInsert expects the statement generated by sx.InsertQuery for the struct pointed at by data, with the
+// arguments given by sx.Values.  The statement returns a result with one row affected, which may be changed on the
+// returned expectation.
+func ExpectInsert(mock sqlmock.Sqlmock, table string, data interface{}) *sqlmock.ExpectedExec {
+	return mock.ExpectExec(sx.InsertQuery(table, data)).
+		WithArgs(driverValues(sx.Values(data))...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// ExpectUpdate expects the statement generated by sx.UpdateQuery for the struct pointed at by data, followed by
+// the given WHERE clause, with the arguments returned by sx.UpdateQuery along with whereArgs.  With numbered
+// placeholders, sx.UpdateQuery leaves only $1 for the WHERE clause, so at most one WHERE argument is supported, and
+// it is expected first; use ExpectUpdatePlaceholder for more.  With "?" placeholders, whereArgs are expected last.
+// The statement returns a result with one row affected, which may be changed on the returned expectation.
+func ExpectUpdate(mock sqlmock.Sqlmock, table string, data interface{}, where string,
+	whereArgs ...interface{}) *sqlmock.ExpectedExec {
+
+	var ph sx.Placeholder = 1
+	return ExpectUpdatePlaceholder(mock, table, data, &ph, where, whereArgs...)
+}
+
+// ExpectUpdatePlaceholder is like ExpectUpdate, but passes ph on to sx.UpdateQuery, as the code under test would do
+// to reserve numbered placeholders for a WHERE clause with several arguments.  For example, with a WHERE clause that
+// uses $1 and $2, ph should be 2.  ph itself is not changed.
+func ExpectUpdatePlaceholder(mock sqlmock.Sqlmock, table string, data interface{}, ph *sx.Placeholder, where string,
+	whereArgs ...interface{}) *sqlmock.ExpectedExec {
+
+	p := *ph
+	query, values := sx.UpdateQuery(table, data, &p)
+	args := make([]interface{}, 0, len(values)+len(whereArgs))
+	if numberedPlaceholders() {
+		args = append(append(args, whereArgs...), values...)
+	} else {
+		args = append(append(args, values...), whereArgs...)
+	}
+	return mock.ExpectExec(query + where).
+		WithArgs(driverValues(args)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// numberedPlaceholders reports whether sx currently generates numbered placeholders.
+func numberedPlaceholders() bool {
+	var p sx.Placeholder
+	return p.Next() != "?"
+}
+
+// driverValues converts a list of arguments into the form expected by WithArgs.
+func driverValues(args []interface{}) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg
+	}
+	return values
+}
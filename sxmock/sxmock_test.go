@@ -0,0 +1,125 @@
+package sxmock_test
+
+import (
+	"testing"
+
+	sx "github.com/travelaudience/go-sx"
+	"github.com/travelaudience/go-sx/sxmock"
+)
+
+type person struct {
+	ID   int64 `sx:",readonly"`
+	Name string
+	Age  int
+	Nick *string
+}
+
+func TestRows(t *testing.T) {
+	db, mock, err := sxmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	nick := "bob"
+	people := []person{{ID: 1, Name: "Alice", Age: 30}, {ID: 2, Name: "Bob", Age: 40, Nick: &nick}}
+
+	mock.ExpectBegin()
+	sxmock.ExpectSelect(mock, "people", people, " WHERE age > ?").WithArgs(20)
+	sxmock.ExpectSelect(mock, "people", []*person{&people[1]}, "")
+	mock.ExpectCommit()
+
+	var got []person
+	var one person
+	err = sx.Do(db, func(tx *sx.Tx) {
+		tx.MustQuery(sx.SelectQuery("people", &person{})+" WHERE age > ?", 20).Each(func(rows *sx.Rows) {
+			var p person
+			rows.MustScans(&p)
+			got = append(got, p)
+		})
+		tx.MustQueryRow(sx.SelectQuery("people", &person{})).MustScans(&one)
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if len(got) != 2 || got[0].ID != 1 || got[0].Name != "Alice" || got[0].Nick != nil ||
+		got[1].Age != 40 || got[1].Nick == nil || *got[1].Nick != "bob" {
+		t.Errorf("unexpected rows %+v", got)
+	}
+	if one.ID != 2 || one.Name != "Bob" {
+		t.Errorf("unexpected row %+v", one)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("mocked expectations were not met: %v", err)
+	}
+}
+
+func TestExpectInsertAndUpdate(t *testing.T) {
+	for _, numbered := range []bool{false, true} {
+		sx.SetNumberedPlaceholders(numbered)
+		db, mock, err := sxmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		p := &person{Name: "Carol", Age: 50}
+		where := " WHERE id = ?"
+		if numbered {
+			where = " WHERE id = $1"
+		}
+
+		mock.ExpectBegin()
+		sxmock.ExpectInsert(mock, "people", p)
+		sxmock.ExpectUpdate(mock, "people", p, where, 3)
+		mock.ExpectCommit()
+
+		err = sx.Do(db, func(tx *sx.Tx) {
+			tx.MustExec(sx.InsertQuery("people", p), sx.Values(p)...)
+			query, values := sx.UpdateQuery("people", p)
+			if numbered {
+				tx.MustExec(query+where, append([]interface{}{3}, values...)...)
+			} else {
+				tx.MustExec(query+where, append(values, 3)...)
+			}
+		})
+		if err != nil {
+			t.Errorf("numbered=%t: unexpected error: %v", numbered, err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("numbered=%t: mocked expectations were not met: %v", numbered, err)
+		}
+	}
+	sx.SetNumberedPlaceholders(false)
+}
+
+func TestExpectUpdatePlaceholder(t *testing.T) {
+	sx.SetNumberedPlaceholders(true)
+	defer sx.SetNumberedPlaceholders(false)
+	db, mock, err := sxmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	p := &person{Name: "Dave", Age: 60}
+	const where = " WHERE id = $1 AND age = $2"
+	var ph sx.Placeholder = 2
+
+	mock.ExpectBegin()
+	sxmock.ExpectUpdatePlaceholder(mock, "people", p, &ph, where, 4, 59)
+	mock.ExpectCommit()
+
+	err = sx.Do(db, func(tx *sx.Tx) {
+		var ph sx.Placeholder = 2
+		query, values := sx.UpdateQuery("people", p, &ph)
+		tx.MustExec(query+where, append([]interface{}{4, 59}, values...)...)
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if ph != 2 {
+		t.Errorf("expected the placeholder to be unchanged, got %d", ph)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("mocked expectations were not met: %v", err)
+	}
+}
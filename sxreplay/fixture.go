@@ -0,0 +1,216 @@
+// Package sxreplay records the statements that a workload runs against a real database, together with their
+// results, and replays them later without a database.
+//
+// To record, wrap a connector or a driver with NewRecorder, run the workload against a *sql.DB opened on the
+// recorder, and save the fixture:
+//
+//	rec := sxreplay.NewRecorder(&sqlite3.SQLiteDriver{}, "file:test.db")
+//	db := sql.OpenDB(rec)
+//	... run the workload with db ...
+//	err := rec.Save("testdata/workload.json")
+//
+// To replay, load the fixture and run the same workload against a *sql.DB opened on the replayer:
+//
+//	rp, err := sxreplay.Load("testdata/workload.json")
+//	db := sql.OpenDB(rp)
+//	... run the workload with db ...
+//	err = rp.Done()
+//
+// The replayer serves the recorded events in order, so the workload must run its statements in a deterministic
+// order, typically from a single goroutine.  If a statement doesn't match the recording, the replayer returns an
+// error that shows the difference.  Prepared statements are recorded as the statements run with them.  Errors are
+// recorded as text only, so the replayed errors don't have the original types.
+package sxreplay
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The kinds of event in a fixture.
+const (
+	opBegin    = "begin"
+	opCommit   = "commit"
+	opRollback = "rollback"
+	opExec     = "exec"
+	opQuery    = "query"
+)
+
+// A Fixture is the sequence of events recorded from a workload.  It is saved as JSON.
+type Fixture struct {
+	Events []*Event `json:"events"`
+}
+
+// An Event is a single interaction with the database.
+type Event struct {
+	Op           string    `json:"op"`                     // "begin", "commit", "rollback", "exec" or "query"
+	Query        string    `json:"query,omitempty"`        // the SQL text, for exec and query
+	Args         []Value   `json:"args,omitempty"`         // the arguments, for exec and query
+	Columns      []string  `json:"columns,omitempty"`      // the column names returned by a query
+	Rows         [][]Value `json:"rows,omitempty"`         // the rows returned by a query
+	LastInsertID *int64    `json:"lastInsertId,omitempty"` // the result of an exec, if the driver supports it
+	RowsAffected *int64    `json:"rowsAffected,omitempty"` // the result of an exec, if the driver supports it
+	Err          string    `json:"error,omitempty"`        // the error returned, if any; for a query, after Rows
+}
+
+// A Value is a driver.Value that keeps its type when saved as JSON.  It is saved as an object with a single key
+// naming the type, for example {"string": "abc"} or {"int": "42"}, or as null.  Integers are saved as strings
+// to keep their precision.
+type Value struct {
+	V interface{} // one of nil, int64, float64, bool, []byte, string or time.Time
+}
+
+// MarshalJSON implements json.Marshaler.
+func (v Value) MarshalJSON() ([]byte, error) {
+	var typ string
+	var val interface{}
+	switch x := v.V.(type) {
+	case nil:
+		return []byte("null"), nil
+	case int64:
+		typ, val = "int", strconv.FormatInt(x, 10)
+	case float64:
+		typ, val = "float", x
+	case bool:
+		typ, val = "bool", x
+	case []byte:
+		typ, val = "bytes", base64.StdEncoding.EncodeToString(x)
+	case string:
+		typ, val = "string", x
+	case time.Time:
+		typ, val = "time", x.Format(time.RFC3339Nano)
+	default:
+		return nil, fmt.Errorf("sxreplay: unsupported value of type %T", v.V)
+	}
+	return json.Marshal(map[string]interface{}{typ: val})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		v.V = nil
+		return nil
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	if len(m) != 1 {
+		return fmt.Errorf("sxreplay: invalid value %s", data)
+	}
+	for typ, raw := range m {
+		var err error
+		switch typ {
+		case "int":
+			var s string
+			if err = json.Unmarshal(raw, &s); err == nil {
+				v.V, err = strconv.ParseInt(s, 10, 64)
+			}
+		case "float":
+			var f float64
+			err = json.Unmarshal(raw, &f)
+			v.V = f
+		case "bool":
+			var b bool
+			err = json.Unmarshal(raw, &b)
+			v.V = b
+		case "bytes":
+			var s string
+			if err = json.Unmarshal(raw, &s); err == nil {
+				v.V, err = base64.StdEncoding.DecodeString(s)
+			}
+		case "string":
+			var s string
+			err = json.Unmarshal(raw, &s)
+			v.V = s
+		case "time":
+			var s string
+			if err = json.Unmarshal(raw, &s); err == nil {
+				v.V, err = time.Parse(time.RFC3339Nano, s)
+			}
+		default:
+			err = fmt.Errorf("sxreplay: unknown value type %q", typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the value as it appears in the fixture.
+func (v Value) String() string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v.V)
+	}
+	return string(b)
+}
+
+// describe returns a one-line description of an event, for diffs.
+func (e *Event) describe() string {
+	bob := strings.Builder{}
+	bob.WriteString(e.Op)
+	if e.Op == opExec || e.Op == opQuery {
+		bob.WriteByte(' ')
+		bob.WriteString(strconv.Quote(e.Query))
+		bob.WriteString(" [")
+		for i, arg := range e.Args {
+			if i > 0 {
+				bob.WriteByte(' ')
+			}
+			bob.WriteString(arg.String())
+		}
+		bob.WriteByte(']')
+	}
+	return bob.String()
+}
+
+// matches reports whether the event recorded as e matches the event requested as actual.
+func (e *Event) matches(actual *Event) bool {
+	if e.Op != actual.Op || e.Query != actual.Query || len(e.Args) != len(actual.Args) {
+		return false
+	}
+	for i := range e.Args {
+		if e.Args[i].String() != actual.Args[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+// eventError returns the recorded error of an event, if any.
+func (e *Event) eventError() error {
+	if e.Err == "" {
+		return nil
+	}
+	return errors.New(e.Err)
+}
+
+// Save writes the fixture to the named file as indented JSON.
+func (f *Fixture) Save(name string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0o644)
+}
+
+// LoadFixture reads a fixture from the named file.
+func LoadFixture(name string) (*Fixture, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fixture{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("sxreplay: %s: %w", name, err)
+	}
+	return f, nil
+}
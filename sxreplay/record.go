@@ -0,0 +1,333 @@
+package sxreplay
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A Recorder is a driver.Connector that passes every statement on to a real database, and records the statements
+// and their results in a Fixture.
+type Recorder struct {
+	connector driver.Connector
+
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+// NewRecorder returns a Recorder for the database named by dsn, opened with drv.
+func NewRecorder(drv driver.Driver, dsn string) *Recorder {
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if c, err := dc.OpenConnector(dsn); err == nil {
+			return NewConnectorRecorder(c)
+		}
+	}
+	return NewConnectorRecorder(dsnConnector{drv, dsn})
+}
+
+// NewConnectorRecorder returns a Recorder for the database reached through c.
+func NewConnectorRecorder(c driver.Connector) *Recorder {
+	return &Recorder{connector: c}
+}
+
+// A dsnConnector is a driver.Connector for drivers that don't provide one.
+type dsnConnector struct {
+	drv driver.Driver
+	dsn string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.drv.Open(c.dsn) }
+func (c dsnConnector) Driver() driver.Driver                        { return c.drv }
+
+// Connect implements driver.Connector.
+func (r *Recorder) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := r.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &recordConn{conn, r}, nil
+}
+
+// Driver implements driver.Connector.
+func (r *Recorder) Driver() driver.Driver {
+	return recorderDriver{r}
+}
+
+// recorderDriver is the driver.Driver of a Recorder.  Connections can only be made through the Recorder itself.
+type recorderDriver struct {
+	r *Recorder
+}
+
+func (d recorderDriver) Open(string) (driver.Conn, error) {
+	return d.r.Connect(context.Background())
+}
+
+// Fixture returns a copy of the events recorded so far.
+func (r *Recorder) Fixture() *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Fixture{Events: append([]*Event(nil), r.fixture.Events...)}
+}
+
+// Save writes the events recorded so far to the named file.
+func (r *Recorder) Save(name string) error {
+	return r.Fixture().Save(name)
+}
+
+// record appends an event to the fixture.
+func (r *Recorder) record(e *Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixture.Events = append(r.fixture.Events, e)
+}
+
+// recordConn wraps a driver.Conn, recording the work done on it.
+type recordConn struct {
+	conn driver.Conn
+	r    *Recorder
+}
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *recordConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &recordStmt{stmt, query, c.r}, nil
+}
+
+func (c *recordConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *recordConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *recordConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	var err error
+	if b, ok := c.conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.conn.Begin()
+	}
+	c.r.record(&Event{Op: opBegin, Err: errorText(err)})
+	if err != nil {
+		return nil, err
+	}
+	return &recordTx{tx, c.r}, nil
+}
+
+func (c *recordConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c *recordConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	res, err := execer.ExecContext(ctx, query, args)
+	if errors.Is(err, driver.ErrSkip) {
+		return nil, err
+	}
+	c.r.recordExec(query, args, res, err)
+	return res, err
+}
+
+func (c *recordConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if errors.Is(err, driver.ErrSkip) {
+		return nil, err
+	}
+	return c.r.recordQuery(query, args, rows, err)
+}
+
+// recordStmt wraps a driver.Stmt, recording each execution as a statement in its own right.
+type recordStmt struct {
+	stmt  driver.Stmt
+	query string
+	r     *Recorder
+}
+
+func (s *recordStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *recordStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *recordStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var res driver.Result
+	var err error
+	if execer, ok := s.stmt.(driver.StmtExecContext); ok {
+		res, err = execer.ExecContext(ctx, args)
+	} else {
+		res, err = s.stmt.Exec(plainValues(args))
+	}
+	s.r.recordExec(s.query, args, res, err)
+	return res, err
+}
+
+func (s *recordStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.stmt.Query(plainValues(args))
+	}
+	return s.r.recordQuery(s.query, args, rows, err)
+}
+
+func (s *recordStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// recordTx wraps a driver.Tx, recording its outcome.
+type recordTx struct {
+	tx driver.Tx
+	r  *Recorder
+}
+
+func (t *recordTx) Commit() error {
+	err := t.tx.Commit()
+	t.r.record(&Event{Op: opCommit, Err: errorText(err)})
+	return err
+}
+
+func (t *recordTx) Rollback() error {
+	err := t.tx.Rollback()
+	t.r.record(&Event{Op: opRollback, Err: errorText(err)})
+	return err
+}
+
+// recordExec records the outcome of an exec.
+func (r *Recorder) recordExec(query string, args []driver.NamedValue, res driver.Result, err error) {
+	e := &Event{Op: opExec, Query: query, Args: argValues(args), Err: errorText(err)}
+	if err == nil {
+		if id, err := res.LastInsertId(); err == nil {
+			e.LastInsertID = &id
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			e.RowsAffected = &n
+		}
+	}
+	r.record(e)
+}
+
+// recordQuery records the outcome of a query.  It reads all of the rows at once, and returns a replay of them in
+// their place.
+func (r *Recorder) recordQuery(query string, args []driver.NamedValue, rows driver.Rows,
+	err error) (driver.Rows, error) {
+
+	e := &Event{Op: opQuery, Query: query, Args: argValues(args)}
+	if err != nil {
+		e.Err = err.Error()
+		r.record(e)
+		return nil, err
+	}
+	defer rows.Close()
+
+	e.Columns = rows.Columns()
+	dest := make([]driver.Value, len(e.Columns))
+	for {
+		if err := rows.Next(dest); err != nil {
+			if err != io.EOF {
+				e.Err = err.Error()
+			}
+			break
+		}
+		row := make([]Value, len(dest))
+		for i, v := range dest {
+			row[i] = normalize(v)
+		}
+		e.Rows = append(e.Rows, row)
+	}
+	r.record(e)
+	return &replayRows{event: e}, nil
+}
+
+// argValues converts the arguments of a statement into Values.
+func argValues(args []driver.NamedValue) []Value {
+	if len(args) == 0 {
+		return nil
+	}
+	values := make([]Value, len(args))
+	for i, arg := range args {
+		values[i] = normalize(arg.Value)
+	}
+	return values
+}
+
+// normalize converts v into one of the types supported by Value, copying byte slices, which drivers may reuse.
+func normalize(v driver.Value) Value {
+	switch x := v.(type) {
+	case []byte:
+		return Value{append([]byte(nil), x...)}
+	case nil, int64, float64, bool, string:
+		return Value{x}
+	}
+	if driver.IsValue(v) {
+		return Value{v}
+	}
+	if converted, err := driver.DefaultParameterConverter.ConvertValue(v); err == nil {
+		return normalize(converted)
+	}
+	return Value{fmt.Sprint(v)}
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+func plainValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
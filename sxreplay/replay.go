@@ -0,0 +1,290 @@
+package sxreplay
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// A Replayer is a driver.Connector that serves the events of a Fixture in order, in place of a real database.
+type Replayer struct {
+	mu      sync.Mutex
+	fixture *Fixture
+	next    int   // index of the next event to be served
+	failure error // the first mismatch, if any
+}
+
+// NewReplayer returns a Replayer for the events in f.
+func NewReplayer(f *Fixture) *Replayer {
+	return &Replayer{fixture: f}
+}
+
+// Load returns a Replayer for the fixture saved in the named file.
+func Load(name string) (*Replayer, error) {
+	f, err := LoadFixture(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(f), nil
+}
+
+// Connect implements driver.Connector.
+func (rp *Replayer) Connect(context.Context) (driver.Conn, error) {
+	return &replayConn{rp}, nil
+}
+
+// Driver implements driver.Connector.
+func (rp *Replayer) Driver() driver.Driver {
+	return replayerDriver{rp}
+}
+
+// replayerDriver is the driver.Driver of a Replayer.  Connections can only be made through the Replayer itself.
+type replayerDriver struct {
+	rp *Replayer
+}
+
+func (d replayerDriver) Open(string) (driver.Conn, error) {
+	return d.rp.Connect(context.Background())
+}
+
+// Done returns the first mismatch between the workload and the recording, if any, or else an error if some of the
+// recorded events were not replayed.
+func (rp *Replayer) Done() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.failure != nil {
+		return rp.failure
+	}
+	if remaining := len(rp.fixture.Events) - rp.next; remaining > 0 {
+		return fmt.Errorf("sxreplay: %d recorded events were not replayed, starting with event %d: %s",
+			remaining, rp.next+1, rp.fixture.Events[rp.next].describe())
+	}
+	return nil
+}
+
+// serve returns the next recorded event, which must match actual.
+func (rp *Replayer) serve(actual *Event) (*Event, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.failure != nil {
+		return nil, rp.failure
+	}
+	if rp.next >= len(rp.fixture.Events) {
+		rp.failure = fmt.Errorf("sxreplay: event %d is not in the recording, which has ended\n+ actual:   %s",
+			rp.next+1, actual.describe())
+		return nil, rp.failure
+	}
+	recorded := rp.fixture.Events[rp.next]
+	if !recorded.matches(actual) {
+		rp.failure = mismatch(rp.next+1, recorded, actual)
+		return nil, rp.failure
+	}
+	rp.next++
+	return recorded, nil
+}
+
+// mismatch returns an error showing the difference between a recorded event and the actual one.
+func mismatch(n int, recorded, actual *Event) error {
+	bob := strings.Builder{}
+	fmt.Fprintf(&bob, "sxreplay: event %d does not match the recording\n", n)
+	fmt.Fprintf(&bob, "- recorded: %s\n", recorded.describe())
+	fmt.Fprintf(&bob, "+ actual:   %s", actual.describe())
+	switch {
+	case recorded.Op != actual.Op:
+	case recorded.Query != actual.Query:
+		i := 0
+		for i < len(recorded.Query) && i < len(actual.Query) && recorded.Query[i] == actual.Query[i] {
+			i++
+		}
+		fmt.Fprintf(&bob, "\nthe queries differ from byte %d: %q versus %q", i, tail(recorded.Query, i),
+			tail(actual.Query, i))
+	case len(recorded.Args) != len(actual.Args):
+		fmt.Fprintf(&bob, "\nexpected %d arguments, got %d", len(recorded.Args), len(actual.Args))
+	default:
+		for i := range recorded.Args {
+			if recorded.Args[i].String() != actual.Args[i].String() {
+				fmt.Fprintf(&bob, "\nargument %d differs: %s versus %s", i+1, recorded.Args[i], actual.Args[i])
+			}
+		}
+	}
+	return errors.New(bob.String())
+}
+
+// tail returns s from byte i, shortened for display.
+func tail(s string, i int) string {
+	const max = 40
+	s = s[i:]
+	if len(s) > max {
+		return s[:max] + "..."
+	}
+	return s
+}
+
+// replayConn serves the recorded events of its Replayer.
+type replayConn struct {
+	rp *Replayer
+}
+
+func (c *replayConn) Prepare(query string) (driver.Stmt, error) {
+	return &replayStmt{query, c.rp}, nil
+}
+
+func (c *replayConn) Close() error {
+	return nil
+}
+
+func (c *replayConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *replayConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	e, err := c.rp.serve(&Event{Op: opBegin})
+	if err != nil {
+		return nil, err
+	}
+	if err := e.eventError(); err != nil {
+		return nil, err
+	}
+	return &replayTx{c.rp}, nil
+}
+
+func (c *replayConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return driver.ErrSkip
+}
+
+func (c *replayConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.rp.exec(query, args)
+}
+
+func (c *replayConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.rp.query(query, args)
+}
+
+// replayStmt is a prepared statement, whose executions are served as individual statements.
+type replayStmt struct {
+	query string
+	rp    *Replayer
+}
+
+func (s *replayStmt) Close() error {
+	return nil
+}
+
+func (s *replayStmt) NumInput() int {
+	return -1
+}
+
+func (s *replayStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.rp.exec(s.query, namedValues(args))
+}
+
+func (s *replayStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.rp.query(s.query, namedValues(args))
+}
+
+func (s *replayStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.rp.exec(s.query, args)
+}
+
+func (s *replayStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.rp.query(s.query, args)
+}
+
+// replayTx serves the outcome of a recorded transaction.
+type replayTx struct {
+	rp *Replayer
+}
+
+func (t *replayTx) Commit() error {
+	e, err := t.rp.serve(&Event{Op: opCommit})
+	if err != nil {
+		return err
+	}
+	return e.eventError()
+}
+
+func (t *replayTx) Rollback() error {
+	e, err := t.rp.serve(&Event{Op: opRollback})
+	if err != nil {
+		return err
+	}
+	return e.eventError()
+}
+
+func (rp *Replayer) exec(query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := rp.serve(&Event{Op: opExec, Query: query, Args: argValues(args)})
+	if err != nil {
+		return nil, err
+	}
+	if err := e.eventError(); err != nil {
+		return nil, err
+	}
+	return replayResult{e}, nil
+}
+
+func (rp *Replayer) query(query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := rp.serve(&Event{Op: opQuery, Query: query, Args: argValues(args)})
+	if err != nil {
+		return nil, err
+	}
+	if e.Columns == nil && e.Err != "" {
+		// The query itself failed.
+		return nil, e.eventError()
+	}
+	return &replayRows{event: e}, nil
+}
+
+// replayResult is the recorded result of an exec.
+type replayResult struct {
+	event *Event
+}
+
+func (r replayResult) LastInsertId() (int64, error) {
+	if r.event.LastInsertID == nil {
+		return 0, errors.New("sxreplay: no LastInsertId was recorded")
+	}
+	return *r.event.LastInsertID, nil
+}
+
+func (r replayResult) RowsAffected() (int64, error) {
+	if r.event.RowsAffected == nil {
+		return 0, errors.New("sxreplay: no RowsAffected was recorded")
+	}
+	return *r.event.RowsAffected, nil
+}
+
+// replayRows serves the recorded rows of a query, followed by its recorded error, if any.
+type replayRows struct {
+	event *Event
+	next  int
+}
+
+func (r *replayRows) Columns() []string {
+	return r.event.Columns
+}
+
+func (r *replayRows) Close() error {
+	return nil
+}
+
+func (r *replayRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.event.Rows) {
+		if err := r.event.eventError(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	for i, v := range r.event.Rows[r.next] {
+		if b, ok := v.V.([]byte); ok {
+			dest[i] = append([]byte(nil), b...)
+		} else {
+			dest[i] = v.V
+		}
+	}
+	r.next++
+	return nil
+}
@@ -0,0 +1,139 @@
+package sxreplay_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+
+	sx "github.com/travelaudience/go-sx"
+	"github.com/travelaudience/go-sx/sxreplay"
+)
+
+type number struct {
+	ID   int64 `sx:",readonly"`
+	Name string
+	Data []byte
+}
+
+// workload runs a few transactions and returns what it read.
+func workload(db *sql.DB, name string) ([]number, error) {
+	var numbers []number
+	err := sx.Do(db, func(tx *sx.Tx) {
+		tx.MustExec("CREATE TABLE IF NOT EXISTS numbers (id integer primary key, name text, data blob)")
+		n := number{Name: name, Data: []byte{1, 2}}
+		tx.MustPrepare(sx.InsertQuery("numbers", &n)).Do(func(stmt *sx.Stmt) {
+			stmt.MustExec(sx.Values(&n)...)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = sx.Do(db, func(tx *sx.Tx) {
+		tx.MustQuery(sx.SelectQuery("numbers", &number{})+" WHERE name = ?", name).Each(func(rows *sx.Rows) {
+			var n number
+			rows.MustScans(&n)
+			numbers = append(numbers, n)
+		})
+		tx.MustExec("SELECT nonsense FROM nowhere")
+	})
+	return numbers, err
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "fixture.json")
+
+	rec := sxreplay.NewRecorder(&sqlite3.SQLiteDriver{}, filepath.Join(dir, "test.db"))
+	db := sql.OpenDB(rec)
+	recorded, recordedErr := workload(db, "one")
+	db.Close()
+	if len(recorded) != 1 || recorded[0].ID != 1 || recorded[0].Name != "one" {
+		t.Fatalf("unexpected rows %+v", recorded)
+	}
+	if recordedErr == nil || !strings.Contains(recordedErr.Error(), "no such table") {
+		t.Fatalf("expected an error about a missing table, got %v", recordedErr)
+	}
+	if err := rec.Save(fixture); err != nil {
+		t.Fatalf("error saving fixture: %v", err)
+	}
+
+	t.Run("record and replay", func(t *testing.T) {
+		rp, err := sxreplay.Load(fixture)
+		if err != nil {
+			t.Fatalf("error loading fixture: %v", err)
+		}
+		db := sql.OpenDB(rp)
+		defer db.Close()
+
+		replayed, replayedErr := workload(db, "one")
+		if !reflect.DeepEqual(replayed, recorded) {
+			t.Errorf("expected %+v, got %+v", recorded, replayed)
+		}
+		if replayedErr == nil || replayedErr.Error() != recordedErr.Error() {
+			t.Errorf("expected error %v, got %v", recordedErr, replayedErr)
+		}
+		if err := rp.Done(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("replay with a mismatched statement", func(t *testing.T) {
+		rp, err := sxreplay.Load(fixture)
+		if err != nil {
+			t.Fatalf("error loading fixture: %v", err)
+		}
+		db := sql.OpenDB(rp)
+		defer db.Close()
+
+		_, err = workload(db, "two")
+		for _, want := range []string{
+			`- recorded: exec "INSERT INTO numbers (name,data) VALUES (?,?)" [{"string":"one"} {"bytes":"AQI="}]`,
+			`+ actual:   exec "INSERT INTO numbers (name,data) VALUES (?,?)" [{"string":"two"} {"bytes":"AQI="}]`,
+			`argument 1 differs: {"string":"one"} versus {"string":"two"}`,
+		} {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("expected an error containing %q, got %v", want, err)
+			}
+		}
+		if rp.Done() == nil {
+			t.Errorf("expected Done to report the mismatch")
+		}
+	})
+
+	t.Run("replay left unfinished", func(t *testing.T) {
+		rp, err := sxreplay.Load(fixture)
+		if err != nil {
+			t.Fatalf("error loading fixture: %v", err)
+		}
+		if err := rp.Done(); err == nil || !strings.Contains(err.Error(), "were not replayed") {
+			t.Errorf("expected an error about unreplayed events, got %v", err)
+		}
+	})
+}
+
+func TestValueJSON(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC)
+	values := []sxreplay.Value{{V: nil}, {V: int64(-42)}, {V: 1.5}, {V: true}, {V: []byte("hi")}, {V: "hello"}, {V: now}}
+	data, err := json.Marshal(values)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	const expected = `[null,{"int":"-42"},{"float":1.5},{"bool":true},{"bytes":"aGk="},{"string":"hello"},` +
+		`{"time":"2024-05-06T07:08:09.00000001Z"}]`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+	var decoded []sxreplay.Value
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, values) {
+		t.Errorf("expected %v, got %v", values, decoded)
+	}
+}
@@ -0,0 +1,76 @@
+// Package sxtest provides transactional test fixtures for code that uses package sx.
+//
+// Each test runs in its own transaction, which is always rolled back when the test ends, so tests stay isolated from
+// each other without having to clean up the database:
+//
+//	func TestSomething(t *testing.T) {
+//		tx := sxtest.Begin(t, db)
+//		tx.MustExec("INSERT INTO ...")
+//		if err := repo.DoSomething(tx.Context()); err != nil {
+//			t.Fatal(err)
+//		}
+//		...
+//	}
+package sxtest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+// errRollback ends the test transaction.
+var errRollback = errors.New("sxtest: end of test")
+
+// Begin begins a transaction on db for the duration of the test t, and returns it.  The transaction is always
+// rolled back when the test ends, during t.Cleanup.  The options are passed on to sx.DoWith.
+//
+// If a Must*** method of the transaction, or of a statement or result obtained from it, fails, or if Fail is called,
+// then the error is reported with t.Fatal.  As usual, failures within Try are returned by Try instead.
+//
+// The transaction's context, as returned by its Context method, makes sx.Do, sx.DoContext and sx.DoWith join the
+// transaction by default (see sx.ContextWithPropagation).  Code under test that is given this context, or a context
+// derived from it, therefore runs in the test's transaction.  If such code fails, its DoContext returns the error as
+// usual, and the test may go on.
+//
+// The transaction must only be used from the goroutine running the test.
+func Begin(t testing.TB, db sx.TxBeginner, opts ...sx.Option) *sx.Tx {
+	t.Helper()
+
+	var ending atomic.Bool
+	opts = append(opts, sx.WithFailHook(func(err error) {
+		if !ending.Load() {
+			t.Fatalf("sxtest: transaction failed: %v", err)
+		}
+	}))
+
+	ctx := sx.ContextWithPropagation(context.Background(), sx.PropagationRequired)
+	txc := make(chan *sx.Tx)
+	done := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- sx.DoWith(ctx, db, func(tx *sx.Tx) {
+			txc <- tx
+			<-done
+			ending.Store(true)
+			tx.Fail(errRollback)
+		}, opts...)
+	}()
+
+	select {
+	case tx := <-txc:
+		t.Cleanup(func() {
+			close(done)
+			if err := <-errc; err != errRollback {
+				t.Errorf("sxtest: unexpected error ending transaction: %v", err)
+			}
+		})
+		return tx
+	case err := <-errc:
+		t.Fatalf("sxtest: cannot begin transaction: %v", err)
+		return nil
+	}
+}
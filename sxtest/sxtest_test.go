@@ -0,0 +1,155 @@
+package sxtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+	"github.com/travelaudience/go-sx/sxtest"
+)
+
+// fakeTB records fatal errors and cleanup functions, so that the behaviour of sxtest can itself be tested.
+type fakeTB struct {
+	testing.TB
+	fatal    string
+	cleanups []func()
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Fatalf(format string, args ...interface{}) {
+	f.fatal = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.fatal = fmt.Sprintf(format, args...)
+}
+
+func (f *fakeTB) Cleanup(c func()) {
+	f.cleanups = append(f.cleanups, c)
+}
+
+// run runs the test function body as the test goroutine would, and then the cleanup functions.
+func (f *fakeTB) run(body func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		body()
+	}()
+	<-done
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func TestBegin(t *testing.T) {
+
+	t.Run("Begin always rolls back", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT alpha").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectRollback()
+
+		tb := &fakeTB{TB: t}
+		tb.run(func() {
+			tx := sxtest.Begin(tb, db)
+			tx.MustExec("INSERT alpha")
+		})
+		if tb.fatal != "" {
+			t.Errorf("unexpected failure: %s", tb.fatal)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("mocked expectations were not met: %v", err)
+		}
+	})
+
+	t.Run("failure is fatal", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		err0 := errors.New("bravo error")
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT bravo").WillReturnError(err0)
+		mock.ExpectRollback()
+
+		tb := &fakeTB{TB: t}
+		reached := false
+		tb.run(func() {
+			tx := sxtest.Begin(tb, db)
+			tx.MustExec("INSERT bravo")
+			reached = true
+		})
+		if reached {
+			t.Errorf("expected the test to stop at the failed statement")
+		}
+		if !strings.Contains(tb.fatal, "bravo error") {
+			t.Errorf("expected a fatal error mentioning %q, got %q", err0, tb.fatal)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("mocked expectations were not met: %v", err)
+		}
+	})
+
+	t.Run("code under test joins the transaction", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		err0 := errors.New("charlie error")
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT charlie 1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT charlie 2").WillReturnError(err0)
+		mock.ExpectRollback()
+
+		// insert is the code under test.
+		insert := func(ctx context.Context, query string) error {
+			return sx.DoContext(ctx, db, func(tx *sx.Tx) {
+				tx.MustExec(query)
+			})
+		}
+
+		tb := &fakeTB{TB: t}
+		tb.run(func() {
+			tx := sxtest.Begin(tb, db)
+			if err := insert(tx.Context(), "INSERT charlie 1"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if err := insert(tx.Context(), "INSERT charlie 2"); !errors.Is(err, err0) {
+				t.Errorf("expected %v, got %v", err0, err)
+			}
+		})
+		if tb.fatal != "" {
+			t.Errorf("unexpected failure: %s", tb.fatal)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("mocked expectations were not met: %v", err)
+		}
+	})
+
+	t.Run("Begin with error", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		err0 := errors.New("delta error")
+
+		mock.ExpectBegin().WillReturnError(err0)
+
+		tb := &fakeTB{TB: t}
+		tb.run(func() {
+			sxtest.Begin(tb, db)
+		})
+		if !strings.Contains(tb.fatal, "delta error") {
+			t.Errorf("expected a fatal error mentioning %q, got %q", err0, tb.fatal)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("mocked expectations were not met: %v", err)
+		}
+	})
+}
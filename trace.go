@@ -0,0 +1,133 @@
+package sx
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// A Tracer observes the transactions and statements run by sx, for example to record spans, metrics or logs.  A
+// Tracer may be registered globally with SetTracer, or for a single transaction with the WithTracer option.
+//
+// BeforeBegin is called before each transaction is begun, and is followed by exactly one call to either AfterCommit
+// or AfterRollback.  AfterRollback is also called when the transaction could not be begun or could not be committed.
+//
+// BeforeQuery is called before each statement run by a Must*** method, and is followed by exactly one call to
+// AfterQuery.  For queries that return rows, AfterQuery is called once the rows have been closed, which Each does
+// automatically, and for MustQueryRow, once the row has been scanned.
+//
+// The context returned by BeforeBegin becomes the transaction's context, and the context returned by BeforeQuery is
+// used to run the statement.  A Tracer may use them to carry a span.  The After methods are passed the same contexts.
+// A Tracer must not modify the QueryInfo that it is passed.
+type Tracer interface {
+	BeforeBegin(ctx context.Context) context.Context
+	AfterCommit(ctx context.Context, elapsed time.Duration)
+	AfterRollback(ctx context.Context, elapsed time.Duration, err error)
+	BeforeQuery(ctx context.Context, q *QueryInfo) context.Context
+	AfterQuery(ctx context.Context, q *QueryInfo)
+}
+
+// A QueryInfo describes a statement run by a Must*** method.  The fields after Args are only set for AfterQuery.
+type QueryInfo struct {
+	Op           string        // the operation: "exec", "query" or "prepare"
+	Query        string        // the SQL text of the statement
+	Args         []interface{} // the arguments of the statement, unredacted
+	Elapsed      time.Duration // the time taken by the statement, including reading rows
+	RowsAffected int64         // the number of rows affected by an exec, or -1 if not applicable or unknown
+	RowsRead     int64         // the number of rows read by a query
+	Err          error         // the error that caused the statement to fail, if any
+}
+
+var globalTracer Tracer
+
+// SetTracer registers a Tracer for all transactions and statements.  The global tracer is called before, and its
+// After methods after, any tracers given with WithTracer.  If t is nil, which is the default, no global tracer is
+// used.  This setting should be made during initialization.
+func SetTracer(t Tracer) {
+	globalTracer = t
+}
+
+// allTracers returns the tracers that apply under cfg, which may be nil, in the order in which they are called
+// before a transaction or a statement.
+func (cfg *config) allTracers() []Tracer {
+	var tracers []Tracer
+	if globalTracer != nil {
+		tracers = append(tracers, globalTracer)
+	}
+	if cfg != nil {
+		tracers = append(tracers, cfg.tracers...)
+	}
+	return tracers
+}
+
+// beforeBegin notifies the tracers that a transaction is about to begin.
+func (cfg *config) beforeBegin(ctx context.Context) context.Context {
+	for _, t := range cfg.allTracers() {
+		ctx = t.BeforeBegin(ctx)
+	}
+	return ctx
+}
+
+// afterCommit notifies the tracers that a transaction has been committed.
+func (cfg *config) afterCommit(ctx context.Context, elapsed time.Duration) {
+	tracers := cfg.allTracers()
+	for i := len(tracers) - 1; i >= 0; i-- {
+		tracers[i].AfterCommit(ctx, elapsed)
+	}
+}
+
+// afterRollback notifies the tracers that a transaction has ended without being committed.
+func (cfg *config) afterRollback(ctx context.Context, elapsed time.Duration, err error) {
+	tracers := cfg.allTracers()
+	for i := len(tracers) - 1; i >= 0; i-- {
+		tracers[i].AfterRollback(ctx, elapsed, err)
+	}
+}
+
+// A queryTrace follows a single statement from BeforeQuery to AfterQuery.
+type queryTrace struct {
+	tracers []Tracer
+	ctx     context.Context
+	start   time.Time
+	info    QueryInfo
+}
+
+// beforeQuery notifies the tracers that a statement is about to run.  It returns the context in which to run the
+// statement, and a queryTrace, which is nil if there are no tracers.
+func (cfg *config) beforeQuery(ctx context.Context, op, query string,
+	args []interface{}) (context.Context, *queryTrace) {
+
+	tracers := cfg.allTracers()
+	if len(tracers) == 0 {
+		return ctx, nil
+	}
+	qt := &queryTrace{
+		tracers: tracers,
+		start:   time.Now(),
+		info:    QueryInfo{Op: op, Query: query, Args: args, RowsAffected: -1},
+	}
+	for _, t := range tracers {
+		ctx = t.BeforeQuery(ctx, &qt.info)
+	}
+	qt.ctx = ctx
+	return ctx, qt
+}
+
+// done notifies the tracers that the statement has finished.  res is the result of an exec, and is otherwise nil.
+// qt may be nil.
+func (qt *queryTrace) done(res sql.Result, rowsRead int64, err error) {
+	if qt == nil {
+		return
+	}
+	qt.info.Elapsed = time.Since(qt.start)
+	qt.info.RowsRead = rowsRead
+	qt.info.Err = err
+	if res != nil {
+		if n, err := res.RowsAffected(); err == nil {
+			qt.info.RowsAffected = n
+		}
+	}
+	for i := len(qt.tracers) - 1; i >= 0; i-- {
+		qt.tracers[i].AfterQuery(qt.ctx, &qt.info)
+	}
+}
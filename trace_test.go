@@ -0,0 +1,212 @@
+package sx_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+type traceKey struct{}
+
+// recordingTracer records the calls made to it as strings in events, which may be shared between tracers.
+type recordingTracer struct {
+	name   string
+	events *[]string
+}
+
+func (r recordingTracer) record(format string, a ...interface{}) {
+	*r.events = append(*r.events, r.name+" "+fmt.Sprintf(format, a...))
+}
+
+func (r recordingTracer) BeforeBegin(ctx context.Context) context.Context {
+	r.record("begin")
+	return context.WithValue(ctx, traceKey{}, r.name)
+}
+
+func (r recordingTracer) AfterCommit(ctx context.Context, elapsed time.Duration) {
+	r.record("commit %v", ctx.Value(traceKey{}))
+}
+
+func (r recordingTracer) AfterRollback(ctx context.Context, elapsed time.Duration, err error) {
+	r.record("rollback %v: %v", ctx.Value(traceKey{}), err)
+}
+
+func (r recordingTracer) BeforeQuery(ctx context.Context, q *sx.QueryInfo) context.Context {
+	r.record("before %s %q %v", q.Op, q.Query, q.Args)
+	return ctx
+}
+
+func (r recordingTracer) AfterQuery(ctx context.Context, q *sx.QueryInfo) {
+	r.record("after %s %q affected=%d read=%d err=%v", q.Op, q.Query, q.RowsAffected, q.RowsRead, q.Err != nil)
+}
+
+func TestTracer(t *testing.T) {
+
+	t.Run("statements are traced", func(t *testing.T) {
+		db, mock := newMock(t)
+		var events []string
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE alpha SET a = ?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectQuery("SELECT a FROM alpha").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(1).AddRow(2))
+		mock.ExpectQuery("SELECT b FROM alpha").WillReturnRows(sqlmock.NewRows([]string{"b"}).AddRow(5))
+		mock.ExpectCommit()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			if tx.Context().Value(traceKey{}) != "alpha" {
+				t.Errorf("expected the transaction's context to come from BeforeBegin")
+			}
+			tx.MustExec("UPDATE alpha SET a = ?", 1)
+			tx.MustQuery("SELECT a FROM alpha").Each(func(rows *sx.Rows) {})
+			var b int
+			tx.MustQueryRow("SELECT b FROM alpha").MustScan(&b)
+		}, sx.WithTracer(recordingTracer{"alpha", &events}))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		expected := []string{
+			"alpha begin",
+			`alpha before exec "UPDATE alpha SET a = ?" [1]`,
+			`alpha after exec "UPDATE alpha SET a = ?" affected=3 read=0 err=false`,
+			`alpha before query "SELECT a FROM alpha" []`,
+			`alpha after query "SELECT a FROM alpha" affected=-1 read=2 err=false`,
+			`alpha before query "SELECT b FROM alpha" []`,
+			`alpha after query "SELECT b FROM alpha" affected=-1 read=1 err=false`,
+			"alpha commit alpha",
+		}
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("expected events\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(events, "\n"))
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("failed statements are traced", func(t *testing.T) {
+		db, mock := newMock(t)
+		var events []string
+		errExec := errors.New("bravo exec error")
+		errRow := errors.New("bravo row error")
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT a FROM bravo").
+			WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(1).AddRow(2).RowError(1, errRow))
+		mock.ExpectExec("DELETE FROM bravo").WillReturnError(errExec)
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			func() {
+				defer func() { recover() }()
+				tx.MustQuery("SELECT a FROM bravo").Each(func(rows *sx.Rows) {})
+			}()
+			tx.MustExec("DELETE FROM bravo")
+		}, sx.WithTracer(recordingTracer{"bravo", &events}))
+		if !errors.Is(err, errExec) {
+			t.Errorf("expected %v, got %v", errExec, err)
+		}
+
+		expected := []string{
+			"bravo begin",
+			`bravo before query "SELECT a FROM bravo" []`,
+			`bravo after query "SELECT a FROM bravo" affected=-1 read=1 err=true`,
+			`bravo before exec "DELETE FROM bravo" []`,
+			`bravo after exec "DELETE FROM bravo" affected=-1 read=0 err=true`,
+			"bravo rollback bravo: " + err.Error(),
+		}
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("expected events\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(events, "\n"))
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("failed begin is traced as a rollback", func(t *testing.T) {
+		db, mock := newMock(t)
+		var events []string
+		err0 := errors.New("charlie error")
+
+		mock.ExpectBegin().WillReturnError(err0)
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			t.Errorf("callback should not run")
+		}, sx.WithTracer(recordingTracer{"charlie", &events}))
+		if err != err0 {
+			t.Errorf("expected %v, got %v", err0, err)
+		}
+
+		expected := []string{"charlie begin", "charlie rollback charlie: charlie error"}
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("expected events %q, got %q", expected, events)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("global and local tracers are nested", func(t *testing.T) {
+		db, mock := newMock(t)
+		var events []string
+		sx.SetTracer(recordingTracer{"global", &events})
+		defer sx.SetTracer(nil)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT delta").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.MustExec("SELECT delta")
+		}, sx.WithTracer(recordingTracer{"local", &events}))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		expected := []string{
+			"global begin",
+			"local begin",
+			`global before exec "SELECT delta" []`,
+			`local before exec "SELECT delta" []`,
+			`local after exec "SELECT delta" affected=0 read=0 err=false`,
+			`global after exec "SELECT delta" affected=0 read=0 err=false`,
+			"local commit local",
+			"global commit local",
+		}
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("expected events\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(events, "\n"))
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("statements outside a transaction are traced", func(t *testing.T) {
+		db, mock := newMock(t)
+		var events []string
+		sx.SetTracer(recordingTracer{"echo", &events})
+		defer sx.SetTracer(nil)
+
+		mock.ExpectExec("SELECT echo").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := sx.Run(db, func(conn *sx.Conn) {
+			conn.MustExec("SELECT echo")
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		expected := []string{
+			`echo before exec "SELECT echo" []`,
+			`echo after exec "SELECT echo" affected=1 read=0 err=false`,
+		}
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("expected events %q, got %q", expected, events)
+		}
+
+		endMock(t, mock)
+	})
+}
@@ -3,12 +3,35 @@ package sx
 import (
 	"context"
 	"database/sql"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"sync/atomic"
+	"time"
 )
 
 // Tx extends sql.Tx with some Must*** methods that panic instead of returning an error code.  Tx objects are used
 // inside of transactions managed by Do.  Panics are caught by Do and returned as errors.
 type Tx struct {
 	*sql.Tx
+	ctx        context.Context // the context with which the transaction was begun
+	cfg        *config         // the options with which the transaction was begun
+	start      time.Time       // the time at which the transaction was begun
+	savepoints int             // number of savepoints created so far, used to generate unique savepoint names
+	onCommit   []func()        // hooks to run after a successful commit
+	onRollback []func(error)   // hooks to run after a rollback or a failed commit
+	stmtCache  *stmtCache      // statements prepared on behalf of the Must*** methods, if enabled
+	bound      []*Stmt         // statements bound to the transaction with MustStmt or MustBind
+
+	rollbackOnly bool        // whether a joined callback has failed, so that the transaction must not be committed
+	rollbackErr  error       // the error from the failed joined callback
+	ended        atomic.Bool // whether the transaction has been committed or rolled back
+}
+
+// A TxBeginner is anything that can begin a transaction.  Both *sql.DB and *sql.Conn are TxBeginners, as are
+// wrappers around them that provide a BeginTx method.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
 }
 
 // An sxError is used to wrap errors that we want to send back to the caller of Do.
@@ -17,125 +40,247 @@ type sxError struct {
 }
 
 // MustExec executes a query without returning any rows.  The args are for any placeholder parameters in the query.
-// In case of error, the transaction is aborted and Do returns the error code.
+// In case of error, the transaction is aborted and Do returns the error code.  The transaction's context is used.
 func (tx *Tx) MustExec(query string, args ...interface{}) sql.Result {
-	return tx.MustExecContext(context.Background(), query, args...)
+	return tx.MustExecContext(tx.Context(), query, args...)
 }
 
 // MustExecContext executes a query without returning any rows.  The args are for any placeholder parameters in the
 // query.  In case of error, the transaction is aborted and Do returns the error code.
 func (tx *Tx) MustExecContext(ctx context.Context, query string, args ...interface{}) sql.Result {
-	res, err := tx.ExecContext(ctx, query, args...)
-	if err != nil {
-		panic(sxError{err})
+	if stmt := tx.cachedStmt(ctx, query); stmt != nil {
+		return stmt.MustExecContext(ctx, args...)
 	}
-	return res
+	return tx.mustExecUncached(ctx, query, args...)
+}
+
+// mustExecUncached is as MustExecContext, but never uses the statement cache.
+func (tx *Tx) mustExecUncached(ctx context.Context, query string, args ...interface{}) sql.Result {
+	return mustExec(ctx, tx.cfg, query, args, func(ctx context.Context) (sql.Result, error) {
+		return tx.ExecContext(ctx, query, args...)
+	})
 }
 
 // MustQuery executes a query that returns rows.  The args are for any placeholder parameters in the query.
-// In case of error, the transaction is aborted and Do returns the error code.
+// In case of error, the transaction is aborted and Do returns the error code.  The transaction's context is used.
 func (tx *Tx) MustQuery(query string, args ...interface{}) *Rows {
-	return tx.MustQueryContext(context.Background(), query, args...)
+	return tx.MustQueryContext(tx.Context(), query, args...)
 }
 
 // MustQueryContext executes a query that returns rows.  The args are for any placeholder parameters in the query.
 // In case of error, the transaction is aborted and Do returns the error code.
 func (tx *Tx) MustQueryContext(ctx context.Context, query string, args ...interface{}) *Rows {
-	rows, err := tx.QueryContext(ctx, query, args...)
-	if err != nil {
-		panic(sxError{err})
+	if stmt := tx.cachedStmt(ctx, query); stmt != nil {
+		return stmt.MustQueryContext(ctx, args...)
 	}
-	return &Rows{rows}
+	return mustQuery(ctx, tx.cfg, query, args, func(ctx context.Context) (*sql.Rows, error) {
+		return tx.QueryContext(ctx, query, args...)
+	})
 }
 
 // MustQueryRow executes a query that is expected to return at most one row.  MustQueryRow always returns a non-nil
-// value.  Errors are deferred until one of the Row's scan methods is called.
+// value.  Errors are deferred until one of the Row's scan methods is called.  The transaction's context is used.
 func (tx *Tx) MustQueryRow(query string, args ...interface{}) *Row {
-	return &Row{tx.QueryRowContext(context.Background(), query, args...)}
+	return tx.MustQueryRowContext(tx.Context(), query, args...)
 }
 
 // MustQueryRowContext executes a query that is expected to return at most one row.  MustQueryRow always returns a
 // non-nil value.  Errors are deferred until one of the Row's scan methods is called.
 func (tx *Tx) MustQueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
-	return &Row{tx.QueryRowContext(ctx, query, args...)}
+	if stmt := tx.cachedStmt(ctx, query); stmt != nil {
+		return stmt.MustQueryRowContext(ctx, args...)
+	}
+	return mustQueryRow(ctx, tx.cfg, query, args, func(ctx context.Context) *sql.Row {
+		return tx.QueryRowContext(ctx, query, args...)
+	})
 }
 
 // MustPrepare creates a prepared statement for later queries or executions.  Multiple queries or executions may be
 // run concurrently from the returned statement.  In case of error, the transaction is aborted and Do returns the
-// error code.
+// error code.  The transaction's context is used, both to prepare the statement and by the statement's methods that
+// don't take a context.
 //
 // The caller must call the statement's Close method when the statement is no longer needed.
 func (tx *Tx) MustPrepare(query string) *Stmt {
-	return tx.MustPrepareContext(context.Background(), query)
+	return tx.MustPrepareContext(tx.Context(), query)
 }
 
 // MustPrepareContext creates a prepared statement for later queries or executions.  Multiple queries or executions
 // may be run concurrently from the returned statement.  In case of error, the transaction is aborted and Do returns
-// the error code.
+// the error code.  The statement's methods that don't take a context use the transaction's context, not ctx.
 //
 // The caller must call the statement's Close method when the statement is no longer needed.
 func (tx *Tx) MustPrepareContext(ctx context.Context, query string) *Stmt {
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		panic(sxError{err})
-	}
-	return &Stmt{stmt}
+	return mustPrepare(ctx, tx.cfg, query, tx.ctx, func(ctx context.Context) (*sql.Stmt, error) {
+		return tx.PrepareContext(ctx, query)
+	})
 }
 
 // Fail aborts and rolls back the transaction, returning the given error code to the caller of Do.  Fail always
 // rolls back the transaction, even if err is nil.
 func (tx *Tx) Fail(err error) {
-	panic(sxError{err})
+	tx.cfg.fail(sxError{err})
+}
+
+// Context returns the context with which the transaction was begun.  The Must*** methods that don't take a context
+// use this one.  The context carries the transaction itself, which can be retrieved with TxFromContext.
+func (tx *Tx) Context() context.Context {
+	return orBackground(tx.ctx)
+}
+
+// orBackground returns ctx, or the background context if ctx is nil.
+func orBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// Try runs the function f under a savepoint.  Within f, if Fail() is invoked or if any Must*** method encounters an
+// error, then the transaction is rolled back to the savepoint and Try returns the error.  The enclosing transaction
+// is not aborted and may continue.  If f runs to completion, then the savepoint is released and Try returns nil.
+//
+// When the savepoint is rolled back, any OnRollback hooks registered within f are run immediately, and any OnCommit
+// hooks registered within f are discarded.
+//
+// Calls to Try may be nested to any depth.  Each savepoint is given a unique name.  If creating, releasing or
+// rolling back to the savepoint fails, then the enclosing transaction is aborted.
+func (tx *Tx) Try(f func(*Tx)) (err error) {
+	tx.savepoints++
+	name := "sx_savepoint_" + strconv.Itoa(tx.savepoints)
+	tx.mustExecUncached(tx.Context(), "SAVEPOINT "+name)
+	nCommit, nRollback := len(tx.onCommit), len(tx.onRollback)
+
+	defer func() {
+		if r := recover(); r != nil {
+			if ourerr, ok := r.(sxError); ok {
+				// Our panic.  Undo the work done by f and return the error code.
+				tx.mustExecUncached(tx.Context(), "ROLLBACK TO SAVEPOINT "+name)
+				err = ourerr.err
+				// Hooks registered by f refer to work that has just been undone.
+				rollbackHooks := tx.onRollback[nRollback:]
+				tx.onCommit, tx.onRollback = tx.onCommit[:nCommit], tx.onRollback[:nRollback]
+				runRollbackHooks(rollbackHooks, err)
+			} else {
+				// Not our panic, so propagate it.
+				panic(r)
+			}
+		}
+	}()
+
+	tx.runShielded(f)
+
+	tx.mustExecUncached(tx.Context(), "RELEASE SAVEPOINT "+name)
+	return nil
+}
+
+// OnCommit registers a hook to be run by Do after the transaction has been successfully committed.  Hooks are run
+// in the order in which they were registered.
+//
+// Hooks run after the transaction has ended, so they must not use the transaction.  Hooks registered within Try are
+// discarded if the savepoint is rolled back.  If a hook panics, then the remaining hooks are still run, after which
+// the first panic is propagated to the caller of Do.
+func (tx *Tx) OnCommit(hook func()) {
+	tx.onCommit = append(tx.onCommit, hook)
+}
+
+// OnRollback registers a hook to be run by Do after the transaction has been rolled back, or after an attempt to
+// commit the transaction has failed.  The hook is passed the error that Do returns, which is nil if the transaction
+// was aborted with Fail(nil).  Hooks are run in the order in which they were registered.
+//
+// Hooks normally run after the transaction has ended, so they must not use the transaction.  The exception is hooks
+// registered within Try: if the savepoint is rolled back, they run straight away, with the error that Try returns,
+// while the enclosing transaction is still open.  Such hooks may use the transaction, but if they fail, the
+// enclosing transaction is aborted.  If a hook panics, then the remaining hooks are still run, after which the first
+// panic is propagated to the caller of Do, or of Try.
+func (tx *Tx) OnRollback(hook func(error)) {
+	tx.onRollback = append(tx.onRollback, hook)
+}
+
+// runCommitHooks runs the given OnCommit hooks.
+func runCommitHooks(hooks []func()) {
+	runHooks(len(hooks), func(i int) { hooks[i]() })
+}
+
+// runRollbackHooks runs the given OnRollback hooks.
+func runRollbackHooks(hooks []func(error), err error) {
+	runHooks(len(hooks), func(i int) { hooks[i](err) })
+}
+
+// runHooks calls hook(i) for each i in [0, n).  A panic in one hook does not prevent the others from running.  The
+// first panic, if any, is propagated once all the hooks have run.
+func runHooks(n int, hook func(int)) {
+	var (
+		p        interface{}
+		panicked bool
+	)
+	for i := 0; i < n; i++ {
+		func() {
+			defer func() {
+				if r := recover(); r != nil && !panicked {
+					p, panicked = r, true
+				}
+			}()
+			hook(i)
+		}()
+	}
+	if panicked {
+		panic(p)
+	}
 }
 
 // Stmt extends sql.Stmt with some Must*** methods that panic instead of returning an error code.  Stmt objects are
-// used inside of transactions managed by Do.  Panics are caught by Do and returned as errors.
+// used inside of transactions managed by Do, or inside of functions run by Run.  Panics are caught by Do or Run and
+// returned as errors.
 type Stmt struct {
 	*sql.Stmt
+	ctx   context.Context // the context used by methods that don't take one
+	cfg   *config         // the options of the transaction, if any
+	query string          // the SQL text of the statement, for error reporting
 }
 
 // MustExec executes a prepared statement with the given arguments and returns an sql.Result summarizing the effect
-// of the statement.  In case of error, the transaction is aborted and Do returns the error code.
+// of the statement.  In case of error, the transaction is aborted and Do returns the error code.  The transaction's
+// context is used.
 func (stmt *Stmt) MustExec(args ...interface{}) sql.Result {
-	return stmt.MustExecContext(context.Background(), args...)
+	return stmt.MustExecContext(orBackground(stmt.ctx), args...)
 }
 
 // MustExecContext executes a prepared statement with the given arguments and returns an sql.Result summarizing the
 // effect of the statement.  In case of error, the transaction is aborted and Do returns the error code.
 func (stmt *Stmt) MustExecContext(ctx context.Context, args ...interface{}) sql.Result {
-	res, err := stmt.ExecContext(ctx, args...)
-	if err != nil {
-		panic(sxError{err})
-	}
-	return res
+	return mustExec(ctx, stmt.cfg, stmt.query, args, func(ctx context.Context) (sql.Result, error) {
+		return stmt.ExecContext(ctx, args...)
+	})
 }
 
 // MustQuery executes a prepared query statement with the given arguments and returns the query results as a *Rows.
-// In case of error, the transaction is aborted and Do returns the error code.
+// In case of error, the transaction is aborted and Do returns the error code.  The transaction's context is used.
 func (stmt *Stmt) MustQuery(args ...interface{}) *Rows {
-	return stmt.MustQueryContext(context.Background(), args...)
+	return stmt.MustQueryContext(orBackground(stmt.ctx), args...)
 }
 
 // MustQueryContext executes a prepared query statement with the given arguments and returns the query results as
 // a *Rows.  In case of error, the transaction is aborted and Do returns the error code.
 func (stmt *Stmt) MustQueryContext(ctx context.Context, args ...interface{}) *Rows {
-	rows, err := stmt.QueryContext(ctx, args...)
-	if err != nil {
-		panic(sxError{err})
-	}
-	return &Rows{rows}
+	return mustQuery(ctx, stmt.cfg, stmt.query, args, func(ctx context.Context) (*sql.Rows, error) {
+		return stmt.QueryContext(ctx, args...)
+	})
 }
 
 // MustQueryRow executes a prepared query that is expected to return at most one row.  MustQueryRow always returns
-// a non-nil value.  Errors are deferred until one of the Row's scan methods is called.
+// a non-nil value.  Errors are deferred until one of the Row's scan methods is called.  The transaction's context is
+// used.
 func (stmt *Stmt) MustQueryRow(args ...interface{}) *Row {
-	return &Row{stmt.QueryRowContext(context.Background(), args...)}
+	return stmt.MustQueryRowContext(orBackground(stmt.ctx), args...)
 }
 
 // MustQueryRowContext executes a prepared query that is expected to return at most one row.  MustQueryRowContext
 // always returns a non-nil value.  Errors are deferred until one of the Row's scan methods is called.
 func (stmt *Stmt) MustQueryRowContext(ctx context.Context, args ...interface{}) *Row {
-	return &Row{stmt.QueryRowContext(ctx, args...)}
+	return mustQueryRow(ctx, stmt.cfg, stmt.query, args, func(ctx context.Context) *sql.Row {
+		return stmt.QueryRowContext(ctx, args...)
+	})
 }
 
 // Do runs a callback function f, providing f with the prepared statement, and then closing the prepared statement
@@ -149,15 +294,34 @@ func (stmt *Stmt) Do(f func(*Stmt)) {
 // scan methods.
 type Row struct {
 	*sql.Row
+	ctx    context.Context    // the context of the query
+	cancel context.CancelFunc // releases the context of the query, if set
+	cfg    *config            // the options of the transaction, if any
+	trace  *queryTrace        // the tracers to notify once the row has been scanned, if any
+	query  string             // the SQL text of the query, for error reporting
+	args   []interface{}      // the arguments of the query, for error reporting
 }
 
 // MustScan copies the columns in the current row into the values pointed at by dest.  In case of error, the
 // transaction is aborted and Do returns the error code.
 func (row *Row) MustScan(dest ...interface{}) {
+	if row.cancel != nil {
+		defer row.cancel()
+	}
+	ctx := orBackground(row.ctx)
+	if err := row.Err(); err != nil {
+		// The query itself failed.
+		err = timeoutError(ctx, err)
+		row.trace.done(nil, 0, err)
+		row.cfg.fail(queryFailed(opQuery, row.query, row.args, err))
+	}
 	err := row.Scan(dest...)
 	if err != nil {
-		panic(sxError{err})
+		err = timeoutError(ctx, err)
+		row.trace.done(nil, 0, err)
+		row.cfg.fail(queryFailed(opScan, row.query, row.args, err))
 	}
+	row.trace.done(nil, 1, nil)
 }
 
 // MustScans copies the columns in the current row into the struct pointed at by dest.  In case of error, the
@@ -170,6 +334,52 @@ func (row *Row) MustScans(dest interface{}) {
 // scan methods.
 type Rows struct {
 	*sql.Rows
+	ctx       context.Context    // the context of the query
+	cancel    context.CancelFunc // releases the context of the query, if set
+	cfg       *config            // the options of the transaction, if any
+	trace     *queryTrace        // the tracers to notify once the rows have been closed, if any
+	rowsRead  int64              // the number of rows read so far, for tracing
+	readErr   error              // the first error met while reading the rows, for tracing
+	plan      *scanPlan          // the scan plan last used by MustScansByName, if any
+	validated reflect.Type       // the type of the struct against which the columns were last validated, if any
+	query     string             // the SQL text of the query, for error reporting
+	args      []interface{}      // the arguments of the query, for error reporting
+}
+
+// Next prepares the next row for reading.  It is as sql.Rows.Next, but also counts the rows read.
+func (rows *Rows) Next() bool {
+	if !rows.Rows.Next() {
+		return false
+	}
+	rows.rowsRead++
+	return true
+}
+
+// Close closes the Rows, preventing further enumeration, and releases the resources associated with the query.
+func (rows *Rows) Close() error {
+	if rows.trace != nil {
+		err := rows.readErr
+		if err == nil {
+			err = rows.Err()
+		}
+		if err != nil {
+			err = timeoutError(orBackground(rows.ctx), err)
+		}
+		defer rows.trace.done(nil, rows.rowsRead, err)
+		rows.trace = nil
+	}
+	err := rows.Rows.Close()
+	if rows.cancel != nil {
+		rows.cancel()
+	}
+	return err
+}
+
+// failed records the first error met while reading the rows, to be reported to the tracers.
+func (rows *Rows) failed(err error) {
+	if rows.readErr == nil {
+		rows.readErr = err
+	}
 }
 
 // MustScan calls Scan to read in a row of the result set.  In case of error, the transaction is aborted and Do
@@ -177,25 +387,30 @@ type Rows struct {
 func (rows *Rows) MustScan(dest ...interface{}) {
 	err := rows.Scan(dest...)
 	if err != nil {
-		panic(sxError{err})
+		err = timeoutError(orBackground(rows.ctx), err)
+		rows.failed(err)
+		rows.cfg.fail(queryFailed(opScan, rows.query, rows.args, err))
 	}
 }
 
 // MustScans copies the columns in the current row into the struct pointed at by dest.  In case of error, the
 // transaction is aborted and Do returns the error code.
+//
+// With the WithColumnValidation option, the result columns are first checked against the fields of the struct.
 func (rows *Rows) MustScans(dest interface{}) {
+	if err := rows.validate(dest); err != nil {
+		rows.failed(err)
+		rows.cfg.fail(queryFailed(opScan, rows.query, rows.args, err))
+	}
 	rows.MustScan(Addrs(dest)...)
 }
 
-// Each iterates over all of the rows in a result set and runs a callback function on each row.
+// Each iterates over all of the rows in a result set and runs a callback function on each row.  If the context of
+// the query is cancelled or its deadline expires, then the iteration stops, the transaction is aborted and Do returns
+// the context's error.
 func (rows *Rows) Each(f func(*Rows)) {
-	defer rows.Close()
-	for rows.Next() {
-		f(rows)
-	}
-	err := rows.Err()
-	if err != nil {
-		panic(sxError{err})
+	for r := range rows.All() {
+		f(r)
 	}
 }
 
@@ -203,12 +418,15 @@ func (rows *Rows) Each(f func(*Rows)) {
 // an error, then the transaction is rolled back and Do returns the error.  If f runs to completion, then the
 // transaction is committed, and Do returns nil.
 //
+// The transaction is begun on db, which is typically an *sql.DB, or an *sql.Conn when session state such as
+// temporary tables must be shared with other work on the same connection.
+//
 // Internally, the Must*** methods panic on error, and Fail() always panics.  The panic aborts execution of f.
 // f should not attempt to recover from the panic.  Instead, Do will catch the panic and return it as an error.
 //
 // A TxOptions may be provided to specify isolation level and/or read-only status.  If no TxOptions is provided,
 // then the default oprtions are used.  Extra TxOptions are ignored.
-func Do(db *sql.DB, f func(*Tx), opts ...sql.TxOptions) error {
+func Do(db TxBeginner, f func(*Tx), opts ...sql.TxOptions) error {
 	return DoContext(context.Background(), db, f, opts...)
 }
 
@@ -219,37 +437,176 @@ func Do(db *sql.DB, f func(*Tx), opts ...sql.TxOptions) error {
 // Internally, the Must*** methods panic on error, and Fail() always panics.  The panic aborts execution of f.
 // f should not attempt to recover from the panic.  Instead, Do will catch the panic and return it as an error.
 //
+// Any other panic in f also rolls back the transaction, and is then propagated to the caller of DoContext.  To have
+// such panics returned as errors instead, use DoWith with the WithPanicRecovery option.
+//
 // A TxOptions may be provided to specify isolation level and/or read-only status.  If no TxOptions is provided,
 // then the default oprtions are used.  Extra TxOptions are ignored.
-func DoContext(ctx context.Context, db *sql.DB, f func(*Tx), opts ...sql.TxOptions) (err error) {
-
-	var opt *sql.TxOptions
+func DoContext(ctx context.Context, db TxBeginner, f func(*Tx), opts ...sql.TxOptions) error {
+	var options []Option
 	if len(opts) > 0 {
-		opt = &opts[0]
+		options = append(options, WithTxOptions(opts[0]))
 	}
+	return DoWith(ctx, db, f, options...)
+}
+
+// DoWith runs the function f in a transaction, like DoContext, with its behaviour adjusted by the given options.
+// See Option for the available options.
+func DoWith(ctx context.Context, db TxBeginner, f func(*Tx), opts ...Option) error {
+	cfg := newConfig(opts)
+	if handled, err := propagate(ctx, f, cfg); handled {
+		return err
+	}
+	if cfg.retry == nil {
+		return doOnce(ctx, db, f, cfg)
+	}
+	return cfg.retry.run(ctx, func() error {
+		return doOnce(ctx, db, f, cfg)
+	})
+}
+
+// DoValue runs the function f in a transaction, like DoWith, and returns the value returned by f.  If the
+// transaction is rolled back, then DoValue returns the zero value of T along with the error, which is nil if the
+// transaction was aborted with Fail(nil).
+func DoValue[T any](ctx context.Context, db TxBeginner, f func(*Tx) T, opts ...Option) (T, error) {
+	var v T
+	err := DoWith(ctx, db, func(tx *Tx) {
+		v = f(tx)
+	}, opts...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// DoValue2 is like DoValue, for functions f that return two values.
+func DoValue2[T, U any](ctx context.Context, db TxBeginner, f func(*Tx) (T, U), opts ...Option) (T, U, error) {
+	var (
+		v T
+		w U
+	)
+	err := DoWith(ctx, db, func(tx *Tx) {
+		v, w = f(tx)
+	}, opts...)
+	if err != nil {
+		var (
+			zeroT T
+			zeroU U
+		)
+		return zeroT, zeroU, err
+	}
+	return v, w, nil
+}
+
+// doOnce runs f in a single transaction.
+func doOnce(ctx context.Context, db TxBeginner, f func(*Tx), cfg *config) (err error) {
+
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, cfg.timeout, &TimeoutError{
+			Limit:   LimitTransaction,
+			Timeout: cfg.timeout,
+		})
+		defer cancel()
+	}
+
+	start := time.Now()
+	ctx = cfg.beforeBegin(ctx)
 
 	var tx *sql.Tx
-	tx, err = db.BeginTx(ctx, opt)
+	tx, err = db.BeginTx(ctx, cfg.txOptions)
+	if err != nil {
+		err = timeoutError(ctx, err)
+		cfg.afterRollback(ctx, time.Since(start), err)
+		return
+	}
+	sxtx := &Tx{Tx: tx, cfg: cfg, start: start}
+	sxtx.ctx = context.WithValue(ctx, txKey{}, sxtx)
+	if cfg.stmtCache {
+		sxtx.stmtCache = &stmtCache{}
+	}
+
+	// This runs the queries.
+	var aborted bool
+	aborted, err = runCallback(sxtx, f, cfg.recoverPanics)
+	sxtx.closeStatements()
+	if aborted {
+		return
+	}
+	if sxtx.rollbackOnly {
+		// A joined callback has failed.
+		tx.Rollback()
+		sxtx.ended.Store(true)
+		err = sxtx.rollbackErr
+		if err == nil {
+			err = ErrRolledBack
+		}
+		sxtx.rolledBack(err)
+		return
+	}
+
+	commitStart := time.Now()
+	err = tx.Commit()
+	sxtx.ended.Store(true)
+	if cfg.stats != nil {
+		cfg.stats.CommitTime = time.Since(commitStart)
+	}
 	if err != nil {
+		err = timeoutError(ctx, err)
+		sxtx.rolledBack(err)
 		return
 	}
+	sxtx.committed()
+	return
+}
 
+// runCallback runs f, and handles any panic that aborts it.  It reports whether f was aborted, in which case the
+// transaction has been rolled back, the OnRollback hooks have been run, and err is the error to be returned by Do.
+//
+// Panics other than our own are propagated after the rollback, unless recoverPanics is set, in which case they are
+// returned as a *PanicError.  Either way, a panic in an OnRollback hook does not replace the callback's panic.
+func runCallback(tx *Tx, f func(*Tx), recoverPanics bool) (aborted bool, err error) {
 	defer func() {
 		if r := recover(); r != nil {
+			aborted = true
+			tx.Rollback()
+			tx.ended.Store(true)
 			if ourerr, ok := r.(sxError); ok {
 				// Our panic.  Unwrap it and return it as an error code.
-				tx.Rollback()
 				err = ourerr.err
-			} else {
-				// Not our panic, so propagate it.
+				if err != nil {
+					err = timeoutError(tx.Context(), err)
+				}
+				tx.rolledBack(err)
+				return
+			}
+			// Not our panic.  Propagating it from here keeps the original stack trace.
+			perr := &PanicError{Value: r, Stack: debug.Stack()}
+			func() {
+				// The callback's panic takes precedence over any panic in the OnRollback hooks.
+				defer func() { recover() }()
+				tx.rolledBack(perr)
+			}()
+			if !recoverPanics {
 				panic(r)
 			}
+			err = perr
 		}
 	}()
+	f(tx)
+	return false, nil
+}
 
-	// This runs the queries.
-	f(&Tx{tx})
+// committed is called once the transaction has been committed.  It notifies the tracers and runs the OnCommit hooks.
+func (tx *Tx) committed() {
+	tx.cfg.afterCommit(tx.Context(), time.Since(tx.start))
+	runCommitHooks(tx.onCommit)
+}
 
-	err = tx.Commit()
-	return
+// rolledBack is called once the transaction has been rolled back, or has failed to commit.  It notifies the tracers
+// and runs the OnRollback hooks.
+func (tx *Tx) rolledBack(err error) {
+	tx.cfg.afterRollback(tx.Context(), time.Since(tx.start), err)
+	runRollbackHooks(tx.onRollback, err)
 }
@@ -4,10 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"math/rand"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 
@@ -75,7 +77,7 @@ func TestMustExec(t *testing.T) {
 		err := sx.Do(db, func(tx *sx.Tx) {
 			tx.MustExec(query)
 		})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -119,7 +121,7 @@ func TestMustExec(t *testing.T) {
 		err := sx.Do(db, func(tx *sx.Tx) {
 			tx.MustExec(query, x, y)
 		})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -162,7 +164,7 @@ func TestMustExec(t *testing.T) {
 		err := sx.Do(db, func(tx *sx.Tx) {
 			tx.MustExec(query)
 		}, sql.TxOptions{Isolation: sql.LevelSerializable})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -209,7 +211,7 @@ func TestMustQueryRow(t *testing.T) {
 			var a0, b0 int64
 			tx.MustQueryRow(query).MustScan(&a0, &b0)
 		})
-		if err != sql.ErrNoRows {
+		if !errors.Is(err, sql.ErrNoRows) {
 			t.Errorf("expected error %v, got %v", sql.ErrNoRows, err)
 		}
 
@@ -229,7 +231,7 @@ func TestMustQueryRow(t *testing.T) {
 			var a0, b0 int64
 			tx.MustQueryRow(query).MustScan(&a0, &b0)
 		})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -250,7 +252,7 @@ func TestMustQueryRow(t *testing.T) {
 			var a0, b0 int64
 			tx.MustQueryRow(query, x).MustScan(&a0, &b0)
 		})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -320,7 +322,7 @@ func TestMustQuery(t *testing.T) {
 		err := sx.Do(db, func(tx *sx.Tx) {
 			tx.MustQuery(query)
 		})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -340,7 +342,7 @@ func TestMustQuery(t *testing.T) {
 		err := sx.Do(db, func(tx *sx.Tx) {
 			tx.MustQuery(query, x)
 		})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -458,7 +460,7 @@ func TestMustQuery(t *testing.T) {
 		})
 		if n != 1 {
 			t.Errorf("Expected 1 row before the row error, got %d", n)
-		} else if err != err0 {
+		} else if !errors.Is(err, err0) {
 			t.Errorf("unexpected error: %v", err)
 		} else if aa != a || bb != b {
 			t.Errorf("Expected result (%d, %d) before the row error, got (%d, %d)",
@@ -511,7 +513,7 @@ func TestMustQuery(t *testing.T) {
 		err := sx.Do(db, func(tx *sx.Tx) {
 			tx.MustQueryContext(context.TODO(), query)
 		})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -561,7 +563,7 @@ func TestMustPrepare(t *testing.T) {
 				stmt.MustExec()
 			})
 		})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -609,7 +611,7 @@ func TestMustPrepare(t *testing.T) {
 				stmt.MustQueryRow().MustScan(&a0, &b0)
 			})
 		})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -662,7 +664,7 @@ func TestMustPrepare(t *testing.T) {
 				stmt.MustQuery()
 			})
 		})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -681,7 +683,7 @@ func TestMustPrepare(t *testing.T) {
 		err := sx.Do(db, func(tx *sx.Tx) {
 			tx.MustPrepare(query)
 		})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -728,7 +730,7 @@ func TestMustPrepare(t *testing.T) {
 				stmt.MustQueryContext(context.TODO())
 			})
 		})
-		if err != err0 {
+		if !errors.Is(err, err0) {
 			t.Errorf("expected error %v, got %v", err0, err)
 		}
 
@@ -774,11 +776,12 @@ func TestFail(t *testing.T) {
 
 	t.Run("panic inside transaction", func(t *testing.T) {
 		// This test ensures that an arbitrary panic inside a transaction is not erroneously caught by us and instead
-		// gets propagated back up as a panic.
+		// gets propagated back up as a panic, after rolling back the transaction.
 		db, mock := newMock(t)
 		err0 := errors.New("zulu error")
 
 		mock.ExpectBegin()
+		mock.ExpectRollback()
 
 		var err error
 		func() {
@@ -817,3 +820,724 @@ func TestFail(t *testing.T) {
 		endMock(t, mock)
 	})
 }
+
+func TestTry(t *testing.T) {
+
+	t.Run("try with success", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "INSERT alpha_try"
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("RELEASE SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			if err := tx.Try(func(tx *sx.Tx) {
+				tx.MustExec(query)
+			}); err != nil {
+				t.Errorf("unexpected error from Try: %v", err)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("try with error and fallback", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query, fallback = "INSERT bravo_try", "UPDATE bravo_try"
+		err0 := errors.New("bravo_try error")
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(query).WillReturnError(err0)
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(fallback).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			if err := tx.Try(func(tx *sx.Tx) {
+				tx.MustExec(query)
+			}); !errors.Is(err, err0) {
+				t.Errorf("expected error %v from Try, got %v", err0, err)
+			}
+			tx.MustExec(fallback)
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("nested try", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("charlie_try error")
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SAVEPOINT sx_savepoint_2").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sx_savepoint_2").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SAVEPOINT sx_savepoint_3").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("RELEASE SAVEPOINT sx_savepoint_3").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("RELEASE SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			err := tx.Try(func(tx *sx.Tx) {
+				if err := tx.Try(func(tx *sx.Tx) { tx.Fail(err0) }); err != err0 {
+					t.Errorf("expected error %v from inner Try, got %v", err0, err)
+				}
+				if err := tx.Try(func(tx *sx.Tx) {}); err != nil {
+					t.Errorf("unexpected error from inner Try: %v", err)
+				}
+			})
+			if err != nil {
+				t.Errorf("unexpected error from outer Try: %v", err)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("savepoint error aborts the transaction", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("delta_try error")
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sx_savepoint_1").WillReturnError(err0)
+		mock.ExpectRollback()
+
+		err := sx.Do(db, func(tx *sx.Tx) {
+			tx.Try(func(tx *sx.Tx) {
+				t.Errorf("callback should not run")
+			})
+		})
+		if !errors.Is(err, err0) {
+			t.Errorf("expected error %v, got %v", err0, err)
+		}
+
+		endMock(t, mock)
+	})
+}
+
+func TestHooks(t *testing.T) {
+
+	t.Run("commit hooks run in order after commit", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		var calls []string
+		err := sx.Do(db, func(tx *sx.Tx) {
+			tx.OnCommit(func() { calls = append(calls, "commit 1") })
+			tx.OnRollback(func(error) { calls = append(calls, "rollback") })
+			tx.OnCommit(func() { calls = append(calls, "commit 2") })
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if a, b := "commit 1,commit 2", strings.Join(calls, ","); a != b {
+			t.Errorf("expected hooks %q, got %q", a, b)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("rollback hooks run in order after rollback", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("alpha_hook error")
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		var calls []string
+		err := sx.Do(db, func(tx *sx.Tx) {
+			tx.OnRollback(func(err error) { calls = append(calls, "rollback 1: "+err.Error()) })
+			tx.OnCommit(func() { calls = append(calls, "commit") })
+			tx.OnRollback(func(err error) { calls = append(calls, "rollback 2: "+err.Error()) })
+			tx.Fail(err0)
+		})
+		if err != err0 {
+			t.Errorf("expected error %v, got %v", err0, err)
+		}
+		if a, b := "rollback 1: alpha_hook error,rollback 2: alpha_hook error", strings.Join(calls, ","); a != b {
+			t.Errorf("expected hooks %q, got %q", a, b)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("failed commit runs rollback hooks", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("bravo_hook error")
+
+		mock.ExpectBegin()
+		mock.ExpectCommit().WillReturnError(err0)
+
+		var committed bool
+		var rollbackErr error
+		err := sx.Do(db, func(tx *sx.Tx) {
+			tx.OnCommit(func() { committed = true })
+			tx.OnRollback(func(err error) { rollbackErr = err })
+		})
+		if err != err0 {
+			t.Errorf("expected error %v, got %v", err0, err)
+		}
+		if committed {
+			t.Errorf("commit hook should not run")
+		}
+		if rollbackErr != err0 {
+			t.Errorf("expected rollback hook error %v, got %v", err0, rollbackErr)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("panicking hook", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		var ran bool
+		var r interface{}
+		func() {
+			defer func() { r = recover() }()
+			sx.Do(db, func(tx *sx.Tx) {
+				tx.OnCommit(func() { panic("charlie_hook panic") })
+				tx.OnCommit(func() { ran = true })
+			})
+		}()
+		if r != "charlie_hook panic" {
+			t.Errorf("expected panic %q, got %v", "charlie_hook panic", r)
+		}
+		if !ran {
+			t.Errorf("expected remaining hooks to run after a panic")
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("hooks registered in a failed try", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("delta_hook error")
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		var calls []string
+		err := sx.Do(db, func(tx *sx.Tx) {
+			tx.OnCommit(func() { calls = append(calls, "outer commit") })
+			tx.Try(func(tx *sx.Tx) {
+				tx.OnCommit(func() { calls = append(calls, "inner commit") })
+				tx.OnRollback(func(err error) { calls = append(calls, "inner rollback: "+err.Error()) })
+				tx.Fail(err0)
+			})
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if a, b := "inner rollback: delta_hook error,outer commit", strings.Join(calls, ","); a != b {
+			t.Errorf("expected hooks %q, got %q", a, b)
+		}
+
+		endMock(t, mock)
+	})
+}
+
+// countingBeginner is a TxBeginner that decorates an *sql.DB.
+type countingBeginner struct {
+	db *sql.DB
+	n  int
+}
+
+func (b *countingBeginner) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	b.n++
+	return b.db.BeginTx(ctx, opts)
+}
+
+func TestTxBeginner(t *testing.T) {
+
+	t.Run("Do on a connection", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT alpha_conn"
+
+		mock.ExpectBegin()
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer conn.Close()
+
+		err = sx.Do(conn, func(tx *sx.Tx) {
+			tx.MustExec(query)
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("Do on a decorator", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT bravo_conn"
+		err0 := errors.New("bravo_conn error")
+
+		mock.ExpectBegin()
+		mock.ExpectExec(query).WillReturnError(err0)
+		mock.ExpectRollback()
+
+		b := &countingBeginner{db: db}
+		err := sx.Do(b, func(tx *sx.Tx) {
+			tx.MustExec(query)
+		})
+		if !errors.Is(err, err0) {
+			t.Errorf("expected error %v, got %v", err0, err)
+		}
+		if b.n != 1 {
+			t.Errorf("expected 1 call to BeginTx, got %d", b.n)
+		}
+
+		endMock(t, mock)
+	})
+}
+
+func TestTxContext(t *testing.T) {
+
+	type key struct{}
+
+	t.Run("Context returns the transaction's context", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		ctx := context.WithValue(context.Background(), key{}, "alpha_ctx")
+		err := sx.DoContext(ctx, db, func(tx *sx.Tx) {
+			if a, b := "alpha_ctx", tx.Context().Value(key{}); a != b {
+				t.Errorf("expected context value %v, got %v", a, b)
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("MustExec uses the transaction's context", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT bravo_ctx"
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		err := sx.DoContext(ctx, db, func(tx *sx.Tx) {
+			cancel()
+			tx.MustExec(query)
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected error %v, got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("Stmt uses the transaction's context", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT charlie_ctx"
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare(query)
+		mock.ExpectRollback()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		err := sx.DoContext(ctx, db, func(tx *sx.Tx) {
+			tx.MustPrepare(query).Do(func(stmt *sx.Stmt) {
+				cancel()
+				stmt.MustQuery()
+			})
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected error %v, got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("Each stops when the context is cancelled", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT delta_ctx"
+		rows := sqlmock.NewRows([]string{"a"}).AddRow(1).AddRow(2).AddRow(3)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).WillReturnRows(rows)
+		mock.ExpectRollback()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		n := 0
+		err := sx.DoContext(ctx, db, func(tx *sx.Tx) {
+			tx.MustQuery(query).Each(func(r *sx.Rows) {
+				n++
+				cancel()
+			})
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected error %v, got %v", context.Canceled, err)
+		}
+		if n != 1 {
+			t.Errorf("expected 1 row before cancellation, got %d", n)
+		}
+	})
+}
+
+func TestDoValue(t *testing.T) {
+
+	t.Run("DoValue with result", func(t *testing.T) {
+		db, mock := newMock(t)
+		a := rand.Int63()
+		const query = "SELECT alpha_value"
+		rows := sqlmock.NewRows([]string{"a"}).AddRow(a)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		a0, err := sx.DoValue(context.Background(), db, func(tx *sx.Tx) int64 {
+			var a0 int64
+			tx.MustQueryRow(query).MustScan(&a0)
+			return a0
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if a0 != a {
+			t.Errorf("expected result %d, got %d", a, a0)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("DoValue with error", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT bravo_value"
+		err0 := errors.New("bravo_value error")
+
+		mock.ExpectBegin()
+		mock.ExpectExec(query).WillReturnError(err0)
+		mock.ExpectRollback()
+
+		a0, err := sx.DoValue(context.Background(), db, func(tx *sx.Tx) string {
+			tx.MustExec(query)
+			return "should not be returned"
+		})
+		if !errors.Is(err, err0) {
+			t.Errorf("expected error %v, got %v", err0, err)
+		}
+		if a0 != "" {
+			t.Errorf("expected zero value, got %q", a0)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("DoValue with failed commit", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("charlie_value error")
+
+		mock.ExpectBegin()
+		mock.ExpectCommit().WillReturnError(err0)
+
+		a0, err := sx.DoValue(context.Background(), db, func(tx *sx.Tx) int {
+			return 42
+		})
+		if err != err0 {
+			t.Errorf("expected error %v, got %v", err0, err)
+		}
+		if a0 != 0 {
+			t.Errorf("expected zero value, got %d", a0)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("DoValue2 with result", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		a0, b0, err := sx.DoValue2(context.Background(), db, func(tx *sx.Tx) (int, string) {
+			return 42, "delta_value"
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if a0 != 42 || b0 != "delta_value" {
+			t.Errorf("expected result (%d, %q), got (%d, %q)", 42, "delta_value", a0, b0)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("DoValue2 with explicit nil fail", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		a0, b0, err := sx.DoValue2(context.Background(), db, func(tx *sx.Tx) (int, string) {
+			tx.Fail(nil)
+			return 42, "echo_value"
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if a0 != 0 || b0 != "" {
+			t.Errorf("expected zero values, got (%d, %q)", a0, b0)
+		}
+
+		endMock(t, mock)
+	})
+}
+
+func TestPanicRecovery(t *testing.T) {
+
+	t.Run("panic is returned as an error", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("alpha_panic error")
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		var rollbackErr error
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.OnRollback(func(err error) { rollbackErr = err })
+			panic(err0)
+		}, sx.WithPanicRecovery())
+
+		var perr *sx.PanicError
+		if !errors.As(err, &perr) {
+			t.Fatalf("expected a *PanicError, got %v", err)
+		}
+		if perr.Value != err0 {
+			t.Errorf("expected panic value %v, got %v", err0, perr.Value)
+		}
+		if !errors.Is(err, err0) {
+			t.Errorf("expected error to wrap %v", err0)
+		}
+		if !strings.Contains(string(perr.Stack), "TestPanicRecovery") {
+			t.Errorf("expected stack trace to include the panicking function, got %s", perr.Stack)
+		}
+		if rollbackErr != err {
+			t.Errorf("expected rollback hook error %v, got %v", err, rollbackErr)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("panic in rollback hook does not replace the original panic", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		func() {
+			defer func() {
+				if r := recover(); r != "original" {
+					t.Errorf("expected panic value %q, got %v", "original", r)
+				}
+			}()
+			sx.Do(db, func(tx *sx.Tx) {
+				tx.OnRollback(func(err error) { panic("hook") })
+				panic("original")
+			})
+		}()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.OnRollback(func(err error) { panic("hook") })
+			panic("original")
+		}, sx.WithPanicRecovery())
+		var perr *sx.PanicError
+		if !errors.As(err, &perr) || perr.Value != "original" {
+			t.Errorf("expected a *PanicError with value %q, got %v", "original", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("non-error panic value", func(t *testing.T) {
+		db, mock := newMock(t)
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			panic("bravo_panic")
+		}, sx.WithPanicRecovery())
+		if a, b := "sx: panic in transaction: bravo_panic", fmt.Sprint(err); a != b {
+			t.Errorf("expected error %q, got %q", a, b)
+		}
+		if errors.Unwrap(err) != nil {
+			t.Errorf("expected nothing to unwrap, got %v", errors.Unwrap(err))
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("Fail is unaffected", func(t *testing.T) {
+		db, mock := newMock(t)
+		err0 := errors.New("charlie_panic error")
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.Fail(err0)
+		}, sx.WithPanicRecovery())
+		if err != err0 {
+			t.Errorf("expected error %v, got %v", err0, err)
+		}
+
+		endMock(t, mock)
+	})
+}
+
+func TestTimeouts(t *testing.T) {
+
+	t.Run("statement timeout", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "UPDATE alpha_timeout"
+
+		mock.ExpectBegin()
+		mock.ExpectExec(query).WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.MustExec(query)
+		}, sx.WithStatementTimeout(10*time.Millisecond))
+
+		var terr *sx.TimeoutError
+		if !errors.As(err, &terr) {
+			t.Fatalf("expected a *TimeoutError, got %v", err)
+		}
+		if terr.Limit != sx.LimitStatement || terr.Timeout != 10*time.Millisecond {
+			t.Errorf("expected statement timeout of 10ms, got %s timeout of %v", terr.Limit, terr.Timeout)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected error to match %v", context.DeadlineExceeded)
+		}
+		var qerr *sx.QueryError
+		if !errors.As(err, &qerr) || qerr.Query != query {
+			t.Errorf("expected a *QueryError for %q, got %v", query, err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("statement timeout on a prepared statement", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT bravo_timeout"
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare(query).ExpectQuery().WillDelayFor(time.Second).WillReturnRows(sqlmock.NewRows([]string{"a"}))
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.MustPrepare(query).Do(func(stmt *sx.Stmt) {
+				stmt.MustQuery().Each(func(*sx.Rows) {})
+			})
+		}, sx.WithStatementTimeout(10*time.Millisecond))
+
+		var terr *sx.TimeoutError
+		if !errors.As(err, &terr) || terr.Limit != sx.LimitStatement {
+			t.Errorf("expected a statement *TimeoutError, got %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("transaction timeout", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "UPDATE charlie_timeout"
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			<-tx.Context().Done()
+			tx.MustExec(query)
+		}, sx.WithTimeout(10*time.Millisecond), sx.WithStatementTimeout(time.Second))
+
+		var terr *sx.TimeoutError
+		if !errors.As(err, &terr) {
+			t.Fatalf("expected a *TimeoutError, got %v", err)
+		}
+		if terr.Limit != sx.LimitTransaction || terr.Timeout != 10*time.Millisecond {
+			t.Errorf("expected transaction timeout of 10ms, got %s timeout of %v", terr.Limit, terr.Timeout)
+		}
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "UPDATE delta_timeout"
+
+		mock.ExpectBegin()
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.MustExec(query)
+		}, sx.WithTimeout(time.Second), sx.WithStatementTimeout(time.Second))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		endMock(t, mock)
+	})
+}
+
+func TestFailHook(t *testing.T) {
+	db, mock := newMock(t)
+	err0 := errors.New("alpha error")
+	err1 := errors.New("bravo error")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SELECT alpha").WillReturnError(err0)
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT sx_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SELECT bravo").WillReturnError(err1)
+	mock.ExpectRollback()
+
+	var hooked []error
+	err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+		tx.Try(func(tx *sx.Tx) {
+			tx.MustExec("SELECT alpha")
+		})
+		tx.MustExec("SELECT bravo")
+	}, sx.WithFailHook(func(err error) {
+		hooked = append(hooked, err)
+	}))
+	if !errors.Is(err, err1) {
+		t.Errorf("expected %v, got %v", err1, err)
+	}
+	if len(hooked) != 1 || hooked[0] != err {
+		t.Errorf("expected the hook to be called once with %v, got %v", err, hooked)
+	}
+
+	endMock(t, mock)
+}
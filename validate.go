@@ -0,0 +1,126 @@
+package sx
+
+import (
+	"reflect"
+	"strings"
+)
+
+// A ColumnMismatchError reports that the columns of a result set don't line up with the fields of a struct, as
+// found by ValidateColumns.
+type ColumnMismatchError struct {
+	Type       reflect.Type // the struct type
+	Missing    []string     // the struct's columns which are not in the result set
+	Extra      []string     // the result columns which match no field of the struct, or repeat an earlier column
+	Misordered []string     // the columns which are in both, but out of the order of the struct
+}
+
+// Error returns a description of the mismatch, naming the columns involved.
+func (e *ColumnMismatchError) Error() string {
+	bob := strings.Builder{}
+	bob.WriteString("sx: result columns don't match the fields of ")
+	bob.WriteString(e.Type.String())
+	for _, part := range []struct {
+		what    string
+		columns []string
+	}{
+		{"missing", e.Missing},
+		{"extra", e.Extra},
+		{"misordered", e.Misordered},
+	} {
+		if len(part.columns) > 0 {
+			bob.WriteString("; ")
+			bob.WriteString(part.what)
+			bob.WriteString(": ")
+			bob.WriteString(strings.Join(part.columns, ", "))
+		}
+	}
+	return bob.String()
+}
+
+// ValidateColumns checks that the result columns given, typically by rows.Columns(), are those of the struct pointed
+// at by dest, in the same order, so that a row can be scanned into it with MustScans or Addrs.  If they are not, then
+// ValidateColumns returns a *ColumnMismatchError.
+//
+// Panics if dest does not point at a struct.
+func ValidateColumns(columns []string, dest interface{}) error {
+	m := matchingOf(dest)
+	position := make(map[string]int, len(m.columns)) // the position of each column in the struct
+	for i, c := range m.columns {
+		position[c.name] = i
+	}
+
+	// Result columns which match no field, or repeat an earlier column, are extra.
+	e := &ColumnMismatchError{Type: m.reflectType}
+	seen := make(map[string]bool, len(columns))
+	var found []string // the result columns which match a field, in the order of the result
+	for _, name := range columns {
+		if _, ok := position[name]; !ok || seen[name] {
+			e.Extra = append(e.Extra, name)
+			continue
+		}
+		seen[name] = true
+		found = append(found, name)
+	}
+	for _, c := range m.columns {
+		if !seen[c.name] {
+			e.Missing = append(e.Missing, c.name)
+		}
+	}
+
+	// The largest set of columns which are already in the order of the struct is taken to be in place, and the
+	// others to have been moved.
+	inOrder := longestInOrder(found, position)
+	for i, name := range found {
+		if !inOrder[i] {
+			e.Misordered = append(e.Misordered, name)
+		}
+	}
+	if e.Missing == nil && e.Extra == nil && e.Misordered == nil {
+		return nil
+	}
+	return e
+}
+
+// longestInOrder finds a longest subsequence of columns whose positions are increasing, and reports which columns are
+// in it.  Of several such subsequences, it prefers the one that ends earliest.
+func longestInOrder(columns []string, position map[string]int) []bool {
+	length := make([]int, len(columns)) // length of the longest subsequence ending at each column
+	prev := make([]int, len(columns))   // previous column in that subsequence, or -1
+	last := -1
+	for i, name := range columns {
+		length[i], prev[i] = 1, -1
+		for j := 0; j < i; j++ {
+			if position[columns[j]] < position[name] && length[j]+1 > length[i] {
+				length[i], prev[i] = length[j]+1, j
+			}
+		}
+		if last < 0 || length[i] > length[last] {
+			last = i
+		}
+	}
+	inOrder := make([]bool, len(columns))
+	for i := last; i >= 0; i = prev[i] {
+		inOrder[i] = true
+	}
+	return inOrder
+}
+
+// validate checks, under WithColumnValidation, that the result columns match the struct pointed at by dest.  The
+// check is made once for each struct type.
+func (rows *Rows) validate(dest interface{}) error {
+	if !rows.cfg.validateColumnsSet() {
+		return nil
+	}
+	t := reflect.TypeOf(dest)
+	if rows.validated == t {
+		return nil
+	}
+	columns, err := rows.Columns()
+	if err == nil {
+		err = ValidateColumns(columns, dest)
+	}
+	if err == nil {
+		rows.validated = t
+	}
+	return err
+}
@@ -0,0 +1,165 @@
+package sx_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	sx "github.com/travelaudience/go-sx"
+)
+
+func TestValidateColumns(t *testing.T) {
+
+	type abc struct {
+		Alpha   int
+		Bravo   string `sx:"b"`
+		Charlie bool
+	}
+
+	type abcd struct {
+		Alpha   int
+		Bravo   string `sx:"b"`
+		Charlie bool
+		Delta   float64
+	}
+
+	var testCases = []struct {
+		name       string
+		datatype   interface{} // &abc{} if nil
+		columns    []string
+		missing    []string
+		extra      []string
+		misordered []string
+	}{
+		{
+			name:    "columns match",
+			columns: []string{"alpha", "b", "charlie"},
+		},
+		{
+			name:    "missing column",
+			columns: []string{"alpha", "charlie"},
+			missing: []string{"b"},
+		},
+		{
+			name:    "extra column",
+			columns: []string{"alpha", "b", "delta", "charlie"},
+			extra:   []string{"delta"},
+		},
+		{
+			name:       "misordered columns",
+			columns:    []string{"alpha", "charlie", "b"},
+			misordered: []string{"b"},
+		},
+		{
+			name:       "missing, extra and misordered columns",
+			columns:    []string{"echo", "b", "alpha"},
+			missing:    []string{"charlie"},
+			extra:      []string{"echo"},
+			misordered: []string{"alpha"},
+		},
+		{
+			name:       "one column moved",
+			datatype:   &abcd{},
+			columns:    []string{"alpha", "charlie", "delta", "b"},
+			misordered: []string{"b"},
+		},
+		{
+			name:    "repeated column",
+			columns: []string{"alpha", "b", "alpha", "charlie"},
+			extra:   []string{"alpha"},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			datatype := c.datatype
+			if datatype == nil {
+				datatype = &abc{}
+			}
+			err := sx.ValidateColumns(c.columns, datatype)
+			if c.missing == nil && c.extra == nil && c.misordered == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var merr *sx.ColumnMismatchError
+			if !errors.As(err, &merr) {
+				t.Fatalf("expected a *ColumnMismatchError, got %v", err)
+			}
+			if merr.Type != reflect.TypeOf(datatype).Elem() || !reflect.DeepEqual(merr.Missing, c.missing) ||
+				!reflect.DeepEqual(merr.Extra, c.extra) || !reflect.DeepEqual(merr.Misordered, c.misordered) {
+				t.Errorf("unexpected mismatch %+v", merr)
+			}
+		})
+	}
+
+	t.Run("error message", func(t *testing.T) {
+		err := sx.ValidateColumns([]string{"echo", "b", "alpha"}, &abc{})
+		const expected = "sx: result columns don't match the fields of sx_test.abc; missing: charlie; extra: echo; " +
+			"misordered: alpha"
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected %q, got %v", expected, err)
+		}
+	})
+}
+
+func TestWithColumnValidation(t *testing.T) {
+
+	type ab struct {
+		Alpha int
+		Bravo int
+	}
+
+	t.Run("MustScans with misordered columns", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT bravo,alpha FROM ab"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"bravo", "alpha"}).AddRow(1, 2))
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			tx.MustQuery(query).Each(func(rows *sx.Rows) {
+				var x ab
+				rows.MustScans(&x)
+			})
+			t.Errorf("expected the transaction to be aborted")
+		}, sx.WithColumnValidation())
+		var qerr *sx.QueryError
+		var merr *sx.ColumnMismatchError
+		if !errors.As(err, &qerr) || qerr.Op != "scan" || !errors.As(err, &merr) ||
+			!reflect.DeepEqual(merr.Misordered, []string{"alpha"}) {
+			t.Errorf("expected a scan error wrapping a *ColumnMismatchError, got %v", err)
+		}
+
+		endMock(t, mock)
+	})
+
+	t.Run("QueryAll with missing column", func(t *testing.T) {
+		db, mock := newMock(t)
+		const query = "SELECT alpha,bravo FROM ab"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"alpha", "bravo"}).AddRow(1, 2).AddRow(3, 4))
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"alpha"}).AddRow(1))
+		mock.ExpectRollback()
+
+		err := sx.DoWith(context.Background(), db, func(tx *sx.Tx) {
+			if got := sx.QueryAll[ab](tx, query); len(got) != 2 || got[1] != (ab{3, 4}) {
+				t.Errorf("unexpected rows %v", got)
+			}
+			sx.QueryAll[ab](tx, query)
+			t.Errorf("expected the transaction to be aborted")
+		}, sx.WithColumnValidation())
+		var merr *sx.ColumnMismatchError
+		if !errors.As(err, &merr) || !reflect.DeepEqual(merr.Missing, []string{"bravo"}) {
+			t.Errorf("expected a *ColumnMismatchError, got %v", err)
+		}
+
+		endMock(t, mock)
+	})
+}